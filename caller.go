@@ -0,0 +1,42 @@
+package formatrus
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// DefaultCallerFormat is the template used for the caller when no CallerFormat is set.
+const DefaultCallerFormat = "{file}:{line}"
+
+// callerText renders the caller's frame using the configured CallerFormatter or CallerFormat.
+func (f *Formatter) callerText(frame *runtime.Frame) string {
+	if f.CallerFormatter != nil {
+		return f.CallerFormatter(frame)
+	}
+
+	file := strings.TrimPrefix(frame.File, f.CallerTrimPrefix)
+	function := frame.Function
+	if f.CallerTrimPackage {
+		function = trimPackage(function)
+	}
+
+	format := f.CallerFormat
+	if format == "" {
+		format = DefaultCallerFormat
+	}
+
+	return strings.NewReplacer(
+		"{function}", function,
+		"{file}", file,
+		"{line}", strconv.Itoa(frame.Line),
+	).Replace(format)
+}
+
+// trimPackage removes the import path from a fully qualified function name, leaving `pkg.(*Type).Method`.
+func trimPackage(function string) string {
+	if i := strings.LastIndexByte(function, '/'); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
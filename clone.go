@@ -0,0 +1,107 @@
+package formatrus
+
+import (
+	"reflect"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Clone returns a copy of the formatter whose options can be changed without affecting the original.
+// The options (including their maps, slices and pointers) and the configuration set by the chainable methods are
+// copied, while internal state such as sequence numbers, deduplication and terminal detection starts afresh.
+func (f *Formatter) Clone() *Formatter {
+	c := &Formatter{}
+	copyOptions(c, f, true)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.redaction != nil {
+		c.redaction = f.redaction.clone()
+	}
+	if f.filter != nil {
+		c.filter = f.filter.clone()
+	}
+	if f.renderers != nil {
+		c.renderers = make(map[reflect.Type]Renderer, len(f.renderers))
+		for t, r := range f.renderers {
+			c.renderers[t] = r
+		}
+	}
+	c.interfaces = append([]reflect.Type(nil), f.interfaces...)
+	if f.renames != nil {
+		c.renames = make(map[string]string, len(f.renames))
+		for from, to := range f.renames {
+			c.renames[from] = to
+		}
+	}
+	c.tees = append([]*tee(nil), f.tees...)
+	c.highlights = append([]highlight(nil), f.highlights...)
+	if f.keyStyles != nil {
+		c.keyStyles = make(map[string]string, len(f.keyStyles))
+		for k, s := range f.keyStyles {
+			c.keyStyles[k] = s
+		}
+	}
+	if f.rules != nil {
+		c.rules = make(map[string][]ColorRule, len(f.rules))
+		for k, r := range f.rules {
+			c.rules[k] = r
+		}
+	}
+	if f.overrides != nil {
+		c.overrides = make(map[logrus.Level]*override, len(f.overrides))
+		for l, o := range f.overrides {
+			// The override is rebuilt from the clone's options when first used.
+			c.overrides[l] = &override{configure: append([]func(*Formatter){}, o.configure...)}
+		}
+	}
+	return c
+}
+
+// copyOptions copies the exported options from src to dst, copying (rather than sharing) maps, slices and pointers if
+// deep.
+func copyOptions(dst, src *Formatter, deep bool) {
+	s := reflect.ValueOf(src).Elem()
+	d := reflect.ValueOf(dst).Elem()
+	for i := 0; i < s.NumField(); i++ {
+		if s.Type().Field(i).PkgPath != "" {
+			continue
+		}
+		v := s.Field(i)
+		if deep {
+			v = copyValue(v)
+		}
+		d.Field(i).Set(v)
+	}
+}
+
+// copyValue returns a shallow copy of a map, slice or pointer value, or the value itself.
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			c.SetMapIndex(k, v.MapIndex(k))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(c, v)
+		return c
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(v.Elem())
+		return c
+	}
+	return v
+}
@@ -0,0 +1,206 @@
+package formatrus
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mgutz/ansi"
+)
+
+// ColorDepth denotes how many colours the terminal can display.
+type ColorDepth int
+
+const (
+	// ColorDepthAuto detects the depth from the `COLORTERM` and `TERM` environment variables.
+	ColorDepthAuto ColorDepth = iota
+	// ColorDepth16 limits styles to the 16 basic (and high intensity) colours.
+	ColorDepth16
+	// ColorDepth256 allows the 256 colour palette.
+	ColorDepth256
+	// ColorDepthTrue allows 24-bit colours.
+	ColorDepthTrue
+)
+
+var (
+	envDepthOnce sync.Once
+	envDepth     ColorDepth
+)
+
+// detectDepth returns the colour depth advertised by the environment.
+func detectDepth() ColorDepth {
+	envDepthOnce.Do(func() {
+		switch term := os.Getenv("TERM"); {
+		case os.Getenv("COLORTERM") == "truecolor" || os.Getenv("COLORTERM") == "24bit":
+			envDepth = ColorDepthTrue
+		case strings.Contains(term, "256color"):
+			envDepth = ColorDepth256
+		case os.Getenv("WT_SESSION") != "":
+			envDepth = ColorDepthTrue
+		default:
+			envDepth = ColorDepth16
+		}
+	})
+	return envDepth
+}
+
+// colourDepth returns the configured, or detected, colour depth.
+func (f *Formatter) colourDepth() ColorDepth {
+	if f.ColorDepth != ColorDepthAuto {
+		return f.ColorDepth
+	}
+	return detectDepth()
+}
+
+// basicPalette is the (xterm) rgb value of each of the 16 basic colours.
+var basicPalette = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0}, {0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+var basicNames = [8]string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// parseHex parses a "#rrggbb" (or "#rgb") colour.
+func parseHex(name string) (rgb [3]int, ok bool) {
+	if !strings.HasPrefix(name, "#") {
+		return rgb, false
+	}
+	hex := name[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return rgb, false
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return rgb, false
+	}
+	return [3]int{int(n >> 16), int(n >> 8 & 0xff), int(n & 0xff)}, true
+}
+
+// paletteRGB returns the rgb value of a colour in the 256 colour palette.
+func paletteRGB(n int) [3]int {
+	switch {
+	case n < 16:
+		return basicPalette[n]
+	case n < 232:
+		n -= 16
+		return [3]int{cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]}
+	}
+	grey := 8 + (n-232)*10
+	return [3]int{grey, grey, grey}
+}
+
+// distance returns the squared distance between two colours.
+func distance(a, b [3]int) int {
+	d := 0
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return d
+}
+
+// nearest256 returns the closest colour in the 256 colour palette, ignoring the (user configurable) basic colours.
+func nearest256(rgb [3]int) int {
+	best, bestDistance := 0, -1
+	for n := 16; n < 256; n++ {
+		if d := distance(rgb, paletteRGB(n)); bestDistance < 0 || d < bestDistance {
+			best, bestDistance = n, d
+		}
+	}
+	return best
+}
+
+// nearest16 returns the closest of the basic colours.
+func nearest16(rgb [3]int) int {
+	best, bestDistance := 0, -1
+	for n, c := range basicPalette {
+		if d := distance(rgb, c); bestDistance < 0 || d < bestDistance {
+			best, bestDistance = n, d
+		}
+	}
+	return best
+}
+
+// downgrade converts a colour name into one that can be displayed at the depth. If it's a 24-bit colour that can be
+// displayed, the name is removed and the rgb value is returned instead.
+func downgrade(name, attrs string, depth ColorDepth) (string, string, *[3]int) {
+	var rgb [3]int
+	if c, ok := parseHex(name); ok {
+		if depth == ColorDepthTrue {
+			return "", attrs, &c
+		}
+		rgb = c
+	} else if n, err := strconv.Atoi(name); err == nil && n >= 0 && n < 256 {
+		if depth != ColorDepth16 {
+			return name, attrs, nil
+		}
+		rgb = paletteRGB(n)
+	} else {
+		return name, attrs, nil
+	}
+
+	if depth != ColorDepth16 {
+		return strconv.Itoa(nearest256(rgb)), attrs, nil
+	}
+	n := nearest16(rgb)
+	if n >= 8 && !strings.Contains(attrs, "h") {
+		attrs += "h"
+	}
+	return basicNames[n%8], attrs, nil
+}
+
+// depthStyle converts the 256 and 24-bit colours in an ansi style so they can be displayed at the depth, returning
+// any 24-bit colours for the foreground and background separately, as the ansi package doesn't know them.
+func depthStyle(style string, depth ColorDepth) (string, *[3]int, *[3]int) {
+	fg, bg := style, ""
+	if i := strings.IndexByte(style, ':'); i >= 0 {
+		fg, bg = style[:i], style[i+1:]
+	}
+
+	name, attrs := splitStyle(fg)
+	name, attrs, fgRGB := downgrade(name, attrs, depth)
+	style = joinStyle(name, attrs)
+	if bg != "" {
+		name, attrs = splitStyle(bg)
+		var bgRGB *[3]int
+		name, attrs, bgRGB = downgrade(name, attrs, depth)
+		return style + ":" + joinStyle(name, attrs), fgRGB, bgRGB
+	}
+	return style, fgRGB, nil
+}
+
+// joinStyle is the reverse of splitStyle.
+func joinStyle(name, attrs string) string {
+	if attrs == "" {
+		return name
+	}
+	return name + "+" + attrs
+}
+
+// ansiColourFunc returns a function that applies the ansi style to a string, at the formatter's colour depth.
+func (f *Formatter) ansiColourFunc(style string) func(string) string {
+	style, fgRGB, bgRGB := depthStyle(style, f.colourDepth())
+	if fgRGB == nil && bgRGB == nil {
+		return ansi.ColorFunc(style)
+	}
+
+	start := ansi.ColorCode(style)
+	if fgRGB != nil {
+		start += fmt.Sprintf("\x1b[38;2;%d;%d;%dm", fgRGB[0], fgRGB[1], fgRGB[2])
+	}
+	if bgRGB != nil {
+		start += fmt.Sprintf("\x1b[48;2;%d;%d;%dm", bgRGB[0], bgRGB[1], bgRGB[2])
+	}
+	return func(s string) string {
+		if s == "" {
+			return s
+		}
+		return start + s + ansi.Reset
+	}
+}
@@ -0,0 +1,51 @@
+package formatrus
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// signature returns a hash of the entry's level, message and fields (but not its time).
+func signature(entry *logrus.Entry) uint64 {
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s", entry.Level, entry.Message)
+	for _, key := range keys {
+		fmt.Fprintf(h, "\x00%s=%#v", key, entry.Data[key])
+	}
+	return h.Sum64()
+}
+
+// dedup records the entry's signature and reports whether it repeats the previous entry (within the
+// DeduplicateWindow). When it doesn't, the number of times the previous entry was seen is returned (if greater than
+// one) so it can be flushed.
+func (f *Formatter) dedup(entry *logrus.Entry) (repeat bool, flushed int) {
+	sig := signature(entry)
+
+	// Overrides share the deduplication of the formatter they were derived from.
+	f = f.root()
+	f.dedupMu.Lock()
+	defer f.dedupMu.Unlock()
+
+	expired := f.DeduplicateWindow > 0 && entry.Time.Sub(f.dedupStart) > f.DeduplicateWindow
+	if f.dedupSeen > 0 && sig == f.dedupSig && !expired {
+		f.dedupSeen++
+		return true, 0
+	}
+
+	if f.dedupSeen > 1 {
+		flushed = f.dedupSeen
+	}
+	f.dedupSig = sig
+	f.dedupSeen = 1
+	f.dedupStart = entry.Time
+	return false, flushed
+}
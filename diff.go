@@ -0,0 +1,134 @@
+package formatrus
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type diffColours struct {
+	add    func(string) string
+	remove func(string) string
+	change func(string) string
+}
+
+// diffPairs returns the `DiffPairs` present in the data, as a map of each before key to its after key.
+// Pairs where either key is redacted are left to render normally.
+func (f *Formatter) diffPairs(data logrus.Fields, redactor *redaction) map[string]string {
+	var pairs map[string]string
+	for _, pair := range f.DiffPairs {
+		_, before := data[pair[0]]
+		_, after := data[pair[1]]
+		if !before || !after || redactor.matches(pair[0]) || redactor.matches(pair[1]) {
+			continue
+		}
+		if pairs == nil {
+			pairs = map[string]string{}
+		}
+		pairs[pair[0]] = pair[1]
+	}
+	return pairs
+}
+
+// isDiffAfter reports whether the key is the after key of one of the pairs.
+func isDiffAfter(pairs map[string]string, key string) bool {
+	for _, after := range pairs {
+		if after == key {
+			return true
+		}
+	}
+	return false
+}
+
+// diff renders the changes between two values, one line per added, removed or changed value.
+// The values are compared before redaction, so that a change to a redacted value is noted without showing it.
+func (f *Formatter) diff(before, after interface{}, colours diffColours) ([]byte, error) {
+	var values [2]interface{}
+	for i, v := range []interface{}{before, after} {
+		data, err := f.marshalUnredacted(v)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &values[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var lines []string
+	diffValues("", values[0], values[1], &lines, colours, f.redactor())
+	if len(lines) == 0 {
+		return []byte("(no changes)"), nil
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// diffValues appends the differences between a and b (found at path) to the lines, with any redacted values hidden.
+func diffValues(path string, a, b interface{}, lines *[]string, colours diffColours, redactor *redaction) {
+	am, aMap := a.(map[string]interface{})
+	bm, bMap := b.(map[string]interface{})
+	if aMap && bMap {
+		keys := make([]string, 0, len(am)+len(bm))
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if _, ok := am[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			av, inA := am[k]
+			bv, inB := bm[k]
+			if redactor.matches(k) {
+				if inA && inB {
+					if !reflect.DeepEqual(av, bv) {
+						*lines = append(*lines, colours.change("~ "+p+": "+redactedChanged))
+					}
+					continue
+				}
+				av, bv = Redacted, Redacted
+			}
+			switch {
+			case !inB:
+				*lines = append(*lines, colours.remove("- "+p+": "+diffText(av, redactor)))
+			case !inA:
+				*lines = append(*lines, colours.add("+ "+p+": "+diffText(bv, redactor)))
+			default:
+				diffValues(p, av, bv, lines, colours, redactor)
+			}
+		}
+		return
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+	label := ""
+	if path != "" {
+		label = path + ": "
+	}
+	before, after := diffText(a, redactor), diffText(b, redactor)
+	if before == after {
+		*lines = append(*lines, colours.change("~ "+label+redactedChanged))
+		return
+	}
+	*lines = append(*lines, colours.change("~ "+label+before+" → "+after))
+}
+
+// redactedChanged notes a change to a value that's redacted.
+const redactedChanged = "(redacted value changed)"
+
+// diffText renders a value for a diff line as compact json, after any redaction.
+func diffText(v interface{}, redactor *redaction) string {
+	data, _ := json.Marshal(redactor.redact(v))
+	return string(data)
+}
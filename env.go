@@ -0,0 +1,80 @@
+package formatrus
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables read by `FromEnv` and `WithEnv`.
+const (
+	// EnvLevelLetters sets LevelLetters (1 to 5).
+	EnvLevelLetters = "FORMATRUS_LEVEL_LETTERS"
+	// EnvCompact sets how data values are compacted: "none", "simple" or "full".
+	EnvCompact = "FORMATRUS_COMPACT"
+	// EnvColor sets whether colour is used: "always", "never" or "auto".
+	EnvColor = "FORMATRUS_COLOR"
+	// EnvTimeFormat sets TimestampFormat, either as a layout or one of "rfc3339", "rfc3339nano", "unix" or
+	// "unixmilli".
+	EnvTimeFormat = "FORMATRUS_TIME_FORMAT"
+)
+
+var namedTimeFormats = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"unix":        TimestampUnix,
+	"unixmilli":   TimestampUnixMilli,
+}
+
+// FromEnv creates a formatter with the defaults from `New`, adjusted by the `FORMATRUS_*` environment variables.
+func FromEnv() (*Formatter, error) {
+	return Build(WithEnv())
+}
+
+// WithEnv applies the `FORMATRUS_*` environment variables, so that the output can be tuned without recompiling.
+// Variables that aren't set leave the options as they are.
+func WithEnv() Option {
+	return func(f *Formatter) error {
+		if v := os.Getenv(EnvLevelLetters); v != "" {
+			letters, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("formatrus: %s must be a number, not %q", EnvLevelLetters, v)
+			}
+			if err = WithLevelLetters(letters)(f); err != nil {
+				return err
+			}
+		}
+
+		switch v := strings.ToLower(os.Getenv(EnvCompact)); v {
+		case "":
+		case "none":
+			f.CompactSimple, f.CompactFull = false, false
+		case "simple":
+			f.CompactSimple, f.CompactFull = true, false
+		case "full":
+			f.CompactSimple, f.CompactFull = false, true
+		default:
+			return fmt.Errorf("formatrus: %s must be none, simple or full, not %q", EnvCompact, v)
+		}
+
+		switch v := strings.ToLower(os.Getenv(EnvColor)); v {
+		case "", "auto":
+		case "always":
+			f.ForceColor, f.DisableColors = true, false
+		case "never":
+			f.ForceColor, f.DisableColors = false, true
+		default:
+			return fmt.Errorf("formatrus: %s must be always, never or auto, not %q", EnvColor, v)
+		}
+
+		if v := os.Getenv(EnvTimeFormat); v != "" {
+			if named, ok := namedTimeFormats[strings.ToLower(v)]; ok {
+				v = named
+			}
+			f.TimestampFormat = v
+		}
+		return nil
+	}
+}
@@ -0,0 +1,25 @@
+package formatrus
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// errorDetail returns the lines of an error's stack trace (for errors carrying a pkg/errors style `StackTrace`) or
+// otherwise its chain of wrapped causes.
+func (f *Formatter) errorDetail(err error) []string {
+	if reflect.ValueOf(err).MethodByName("StackTrace").IsValid() {
+		lines := strings.Split(strings.TrimRight(fmt.Sprintf("%+v", err), "\n"), "\n")
+		if len(lines) > 1 {
+			return lines[1:]
+		}
+	}
+
+	var lines []string
+	for cause := errors.Unwrap(err); cause != nil; cause = errors.Unwrap(cause) {
+		lines = append(lines, "caused by: "+f.safeText(cause.Error))
+	}
+	return lines
+}
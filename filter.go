@@ -0,0 +1,107 @@
+package formatrus
+
+import "github.com/sirupsen/logrus"
+
+type fieldFilter struct {
+	hide  map[string]bool
+	only  map[string]bool
+	below map[string]logrus.Level
+}
+
+// Hide prevents the given data keys from being rendered (chainable call).
+func (f *Formatter) Hide(keys ...string) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ff := f.filter.clone()
+	for _, key := range keys {
+		ff.hide[key] = true
+	}
+	f.filter = ff
+	return f
+}
+
+// HideBelow prevents the given data keys from being rendered for entries less severe than the level, such as hiding
+// "caller" and "goroutine" below `logrus.ErrorLevel` (chainable call).
+func (f *Formatter) HideBelow(level logrus.Level, keys ...string) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ff := f.filter.clone()
+	for _, key := range keys {
+		ff.below[key] = level
+	}
+	f.filter = ff
+	return f
+}
+
+// Only restricts the rendered data keys to those given, across all calls (chainable call).
+func (f *Formatter) Only(keys ...string) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ff := f.filter.clone()
+	for _, key := range keys {
+		ff.only[key] = true
+	}
+	f.filter = ff
+	return f
+}
+
+// fieldFilter returns the current field filter, or nil if there is none.
+func (f *Formatter) fieldFilter() *fieldFilter {
+	f = f.root()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.filter
+}
+
+// clone copies the filter so it can be changed without affecting entries being formatted.
+func (ff *fieldFilter) clone() *fieldFilter {
+	c := &fieldFilter{
+		hide:  map[string]bool{},
+		only:  map[string]bool{},
+		below: map[string]logrus.Level{},
+	}
+	if ff != nil {
+		for key := range ff.hide {
+			c.hide[key] = true
+		}
+		for key := range ff.only {
+			c.only[key] = true
+		}
+		for key, level := range ff.below {
+			c.below[key] = level
+		}
+	}
+	return c
+}
+
+// visible reports whether the data key should be rendered.
+func (ff *fieldFilter) visible(key string) bool {
+	if ff == nil {
+		return true
+	}
+	if ff.hide[key] {
+		return false
+	}
+	return len(ff.only) == 0 || ff.only[key]
+}
+
+// keyVisible reports whether the data key should be rendered for an entry at the level, taking HideKeys and
+// HideBelow into account as well as the filter.
+func (f *Formatter) keyVisible(ff *fieldFilter, key string, level logrus.Level) bool {
+	for _, k := range f.HideKeys {
+		if k == key {
+			return false
+		}
+	}
+	if !ff.visible(key) {
+		return false
+	}
+	if ff == nil {
+		return true
+	}
+	min, ok := ff.below[key]
+	return !ok || f.atLeast(level, min)
+}
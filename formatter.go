@@ -4,28 +4,23 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"reflect"
 	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
+	"github.com/fatih/color"
 	"github.com/hokaccha/go-prettyjson"
-	"github.com/mgutz/ansi"
 	"github.com/norganna/depict"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/crypto/ssh/terminal"
-)
-
-var (
-	green   = ansi.ColorFunc("green")
-	yellow  = ansi.ColorFunc("yellow")
-	red     = ansi.ColorFunc("red")
-	blue    = ansi.ColorFunc("blue")
-	cyan    = ansi.ColorFunc("cyan")
-	magenta = ansi.ColorFunc("magenta")
-	whiteH  = ansi.ColorFunc("magenta+h")
-	blackH  = ansi.ColorFunc("black+h")
 )
 
 func noColour(s string) string {
@@ -40,6 +35,7 @@ type sorter struct {
 	order map[string]int
 	pri   map[string]int
 	keys  []string
+	less  func(a, b string) bool
 }
 
 func (s *sorter) Len() int {
@@ -73,292 +69,1705 @@ func (s *sorter) Less(i, j int) bool {
 			return true
 		}
 	}
+	if s.less != nil {
+		return s.less(a, b)
+	}
 	return strings.Compare(a, b) < 1
 }
 
+// Position denotes where an element of the log line is placed.
+type Position int
+
+const (
+	// Left places the element at the start of the line.
+	Left Position = iota
+	// Right places the element at the end of the line.
+	Right
+)
+
 // Formatter should not be instantiated directly as it doesn't have any values set.
 // Prefer to use `DefaultFormatter` or `New()` if you need to make changes to it.
+// Format is safe for concurrent use and never modifies the options, but options should be set before the formatter
+// is first used. The chainable methods (such as `Order`) may be called at any time.
 type Formatter struct {
-	// LevelLetters denotes the number of letters to show for the level (from 1 to 5).
+	// LevelLetters denotes the number of letters to show for the level (from 1 to 5, with 0 meaning 3).
 	LevelLetters int
+	// LevelText replaces the level text for the given levels (eg "WARNING" or "FATAL!"), used as is instead of being
+	// derived from LevelLetters. Use LevelColors to change their colour.
+	LevelText map[logrus.Level]string
+	// LevelCase sets the case of the level text, falling back to LevelUpper and LevelLower when left as the default.
+	LevelCase LevelCase
 	// LevelUpper sets whether to UPPERCASE the level text.
+	//
+	// Deprecated: use LevelCase.
 	LevelUpper bool
-	// LevelLower sets whether to lowercase the level text.
+	// LevelLower sets whether to lowercase the level text (taking precedence over LevelUpper).
+	//
+	// Deprecated: use LevelCase.
 	LevelLower bool
+	// TimestampFormat is the time layout for the timestamp, or one of `TimestampUnix` or `TimestampUnixMilli`
+	// (defaults to `DefaultTimestampFormat`).
+	TimestampFormat string
+	// TimePrecision overrides the fractional seconds shown by the TimestampFormat.
+	TimePrecision TimePrecision
+	// TimeMode shows the time since the process started or since the previous entry instead of the entry's time.
+	TimeMode TimeMode
+	// TimestampUTC renders timestamps in UTC instead of local time.
+	TimestampUTC bool
+	// DisableTimestamp omits the timestamp, so lines start with the level.
+	DisableTimestamp bool
+	// JournaldPriority starts every line with the systemd `<N>` priority prefix for the entry's level (such as `<4>`
+	// for a warning), and omits the timestamp as journald records its own.
+	JournaldPriority bool
+	// LevelIcons shows a glyph for the level alongside, or instead of, the level text in the terminal layout.
+	// Other output keeps the level text.
+	LevelIcons IconMode
+	// LevelIcon replaces the glyphs from `DefaultLevelIcons` for the given levels.
+	LevelIcon map[logrus.Level]string
+	// LevelFirst places the level text before the timestamp.
+	LevelFirst bool
 	// CompactFull makes all json structures take a single line.
 	CompactFull bool
 	// CompactSimple causes any short json structures to be compact, but larger ones will still be block indented.
 	CompactSimple bool
+	// CompactThreshold is the size in bytes below which CompactSimple compacts a structure (defaults to
+	// DefaultCompactThreshold). CompactTerminal compacts structures that fit in the terminal beside the key column.
+	CompactThreshold int
 	// MessageAfter places the message text on a new line after any data.
 	MessageAfter bool
+	// MessagePrefix is the gutter written before the message when it's placed on its own line.
+	MessagePrefix string
+	// MessageGutter is written before each continuation line of a multi-line message (e.g. "│ "), after the
+	// indentation that aligns it with the first line.
+	MessageGutter string
+	// ColorMessage tints the message in the style of its level, so the severity is obvious without the level text.
+	ColorMessage bool
 	// CompactMessage allows short messages without any data lines to be placed on the log line
 	CompactMessage bool
+	// WrapWidth soft wraps long messages and compact values at word boundaries to fit within the given number of
+	// columns, or the width of the terminal for `WrapTerminal` (0 doesn't wrap). Only the terminal layout is wrapped.
+	WrapWidth int
+	// ShowPID adds the process id to a dimmed column at the end of the header line, right aligned to the WrapWidth
+	// or terminal width.
+	ShowPID bool
+	// ShowHostname adds the host name to the end of the header line.
+	ShowHostname bool
+	// ShowGoroutine adds the id of the logging goroutine to the end of the header line.
+	ShowGoroutine bool
+	// TrailerFunc, when set, returns extra text to add to the end of the header line.
+	TrailerFunc func(entry *logrus.Entry) string
+	// EmphasiseSevere renders the level of fatal and panic entries in the theme's Emphasis style (inverse red).
+	EmphasiseSevere bool
+	// SevereBanner surrounds fatal and panic entries with full width separators in the theme's Banner style.
+	// Both of these only apply to the terminal layout.
+	SevereBanner bool
+	// Separator is a dim rule (such as "─", which is repeated across the line) written between entries in the
+	// terminal layout, to make dense output easier to scan. Use ForLevel to only separate some levels.
+	Separator string
+	// SeparatorEvery writes the Separator before every Nth entry, rather than between all of them.
+	SeparatorEvery int
+	// Hyperlinks turns URLs in messages and data values into OSC 8 hyperlinks when the output is coloured and the
+	// terminal is known to support them (`FORCE_HYPERLINK=1` forces them on).
+	Hyperlinks bool
+	// LinkKeys are data keys whose string values are always hyperlinked (file paths are linked as file URLs).
+	LinkKeys []string
+	// CuddleLongMessages allows messages of any length to be placed on the log line when there are no data lines.
+	CuddleLongMessages bool
 	// ParagraphAll adds a newline after any log line.
 	ParagraphAll bool
 	// ParagraphBlock adds a newline after any log line block (multi-line log messages)
 	ParagraphBlock bool
+	// NumberGrouping inserts thousands separators (commas) into top-level integer and float values.
+	NumberGrouping bool
+	// ForceColor renders colour output even when the output is not a terminal.
+	// Terminal detection recognises an `*os.File`, a TerminalHinter, or a wrapper with an `Unwrap() io.Writer` or
+	// `Writers() []io.Writer` method as the logger's output, so other writers, such as an `io.MultiWriter` of stderr
+	// and a file, will render without colour unless this (or `SetOutputIsTerminal`) is set.
+	ForceColor bool
+	// ForceColors is the same as ForceColor, named to match logrus's TextFormatter.
+	ForceColors bool
+	// TerminalChecker, when set, replaces the built in terminal detection (results are still cached per writer and
+	// SetOutputIsTerminal overrides still apply).
+	TerminalChecker TerminalChecker
+	// DisableWindowsColor stops the formatter from enabling ansi processing on Windows consoles, treating them as
+	// non-terminals. Legacy consoles can instead wrap the logger's output with `go-colorable` and set ForceColor.
+	DisableWindowsColor bool
+	// ColorDepth sets how many colours the terminal can display, and so how theme colours are downgraded. By default
+	// it's detected from the `COLORTERM` and `TERM` environment variables.
+	ColorDepth ColorDepth
+	// DisableColors turns off colour output, even on a terminal (the terminal layout is still used there).
+	// It takes priority over any options that force colour.
+	// The `NO_COLOR`, `CLICOLOR_FORCE` and `CLICOLOR` environment variables are also honoured, with `NO_COLOR` or
+	// `CLICOLOR=0` disabling colour and `CLICOLOR_FORCE` forcing it where the output isn't a terminal.
+	DisableColors bool
+	// PreviewDepth limits how many levels of nested structure are rendered, replacing deeper content with
+	// `{…}` or `[…]` placeholders (0 renders everything).
+	PreviewDepth int
+	// MaxDepth stops rendering nested structures below the given depth, replacing deeper maps and arrays with a
+	// summary such as `{…3 fields}` (0 renders everything). It also limits how deeply values are portrayed, which
+	// keeps structures with back references cheap to log.
+	MaxDepth int
+	// MaxElements limits how many items of an array, or keys of a map (in sorted order), are rendered, noting how
+	// many more were left out such as `… (+123 more)` (0 renders everything).
+	MaxElements int
+	// PrefixCollidingKeys renders data keys that share a name with a header component (time, level, msg) as
+	// `data.<key>` so they can't be confused with the header.
+	PrefixCollidingKeys bool
+	// FixZeroTime substitutes the current time for entries that have a zero timestamp.
+	FixZeroTime bool
+	// FileColor renders colour output regardless of the writer, like `ForceColor`, but guarantees that the only
+	// escape sequences emitted are SGR colour sequences (any others, e.g. from the message, are stripped).
+	FileColor bool
+	// Deduplicate collapses consecutive identical entries (same level, message and fields). Repeats produce no
+	// output, and a `(repeated N times)` line is written ahead of the next different entry. This makes the
+	// formatter stateful, so share it only between loggers whose output should be collapsed together.
+	Deduplicate bool
+	// DeduplicateWindow limits how long repeats are collapsed for; a repeat after the window (measured from the entry
+	// that was last written) flushes the count and is written again. 0 collapses repeats indefinitely.
+	DeduplicateWindow time.Duration
+	// VerboseRateLimit renders at most the given number of entries per second with the same level and message in full,
+	// rendering any more as single lines (as per OneLine) to keep the terminal usable during a storm (0 is unlimited).
+	VerboseRateLimit int
+	// ShowSequence prepends a monotonically increasing `#N` sequence number to each entry.
+	ShowSequence bool
+	// InterpolateMessage replaces `{key}` tokens in the message with the value of the matching data field, and
+	// omits the consumed fields from the data lines. Tokens without a matching field are left as is.
+	InterpolateMessage bool
+	// FieldsMinLevel, when set, only renders the data fields of entries at least as severe as the level, leaving less
+	// severe entries with just their message (see WithFieldsMinLevel). By default every entry has its fields.
+	FieldsMinLevel *logrus.Level
+	// GroupDottedKeys renders dotted data keys (such as `http.method` and `http.status`) together as a nested block
+	// under their first segment (`http`).
+	GroupDottedKeys bool
+	// HideKeys are data keys that aren't rendered, which can be varied by level with ForLevel (see also Hide and
+	// HideBelow). These, like HideBelow's keys, can also name the header's "caller" and the trailer's "pid", "host" and
+	// "goroutine".
+	HideKeys []string
+	// InlineFields places short fields on the header line (after the message, when it's cuddled) in `key=value` form,
+	// leaving only long or multi-line values for the indented block, in the terminal layout.
+	InlineFields bool
+	// FlattenNested is the opposite of GroupDottedKeys, rendering the leaves of nested maps and structures as separate
+	// fields under dotted keys (such as `http.method=GET`) rather than as json blocks. GroupDottedKeys takes precedence.
+	FlattenNested bool
+	// DiffPairs lists pairs of before and after data keys (such as `{"old", "new"}`) that are rendered as a single
+	// coloured diff of their values, rather than as two separate values, in the terminal layout.
+	DiffPairs [][2]string
+	// FieldHook, when set, is called for every entry and the fields it returns are added to (or replace) the entry's
+	// data for rendering, without modifying the entry itself. It can be used to pull values from `entry.Context`.
+	FieldHook func(entry *logrus.Entry) logrus.Fields
+	// Strict causes Format to return an error for malformed entries (such as a bad `_order` value or a field that
+	// can't be marshalled) instead of quietly making the best of them.
+	Strict bool
+	// StrictErrors lets panics from rendering values (such as in a `String()` or `Error()` method) propagate, rather
+	// than showing `<panic rendering field: ...>` in their place.
+	StrictErrors bool
+	// NiceNetTypes renders `net.IP`, `net.IPNet` and `url.URL` values in their usual string forms.
+	NiceNetTypes bool
+	// NiceHTTPTypes renders `*http.Request` and `*http.Response` values as a summary of their method, url, status,
+	// content length and HTTPHeaders (with SensitiveHTTPHeaders, url passwords and query values redacted).
+	NiceHTTPTypes bool
+	// HTTPHeaders are the headers shown by NiceHTTPTypes (defaults to DefaultHTTPHeaders).
+	HTTPHeaders []string
+	// OneLine renders the entire entry, including the message and compacted data, on a single line.
+	OneLine bool
+	// Minimal is a preset for command line tools, rendering `LEVEL message key=value ...` on one line without a
+	// timestamp, whether or not the output is a terminal (NonTerminalJSON is ignored).
+	Minimal bool
+	// Deterministic makes the output stable for golden file tests: timestamps, process metadata and terminal widths
+	// are omitted, and terminal detection is skipped so that colour is never used (ForceColor still selects the
+	// terminal layout, without colour).
+	Deterministic bool
+	// SimplifyBelow, when set, renders entries less severe than its level on a single line, as per OneLine.
+	SimplifyBelow *logrus.Level
+	// Theme sets the styles used for each element of coloured output.
+	Theme Theme
+	// LevelColors overrides the colour (an ansi style such as "green" or "red+b") used for a level's text.
+	LevelColors map[logrus.Level]string
+	// LevelSeverityOrder ranks levels from most to least severe for the level threshold options (such as
+	// SimplifyBelow), replacing logrus's numeric order. Levels that aren't listed are compared in logrus's order.
+	LevelSeverityOrder []logrus.Level
+	// PostProcess, if set, is given the final rendered bytes of each entry and returns the bytes to output.
+	PostProcess func([]byte) []byte
+	// KeyMinWidth is the minimum width of the data key column.
+	KeyMinWidth int
+	// KeyMaxWidth is the maximum width the data key column grows to for long keys (0 is unlimited).
+	KeyMaxWidth int
+	// FixedKeyWidth, if set, makes the data key column this width for every entry so data lines up across entries.
+	FixedKeyWidth int
+	// KeyAlign aligns data keys to the Left or Right of the key column.
+	KeyAlign Position
+	// CompactKeys lists data keys that are always rendered compact, regardless of size.
+	CompactKeys []string
+	// ExpandKeys lists data keys that are always rendered as indented blocks, regardless of size.
+	ExpandKeys []string
+	// UserKey is the data key whose (string) value is shown as `user@` before the prefix.
+	UserKey string
+	// PrefixKeys are the data keys whose (string) values are joined with PrefixJoiner to make the prefix.
+	PrefixKeys []string
+	// PrefixJoiner separates the values of the PrefixKeys.
+	PrefixJoiner string
+	// TraceExtractor, when set, pulls trace and span ids from each entry's context, which are rendered as a dimmed
+	// suffix to the header line (or as fields in json output).
+	TraceExtractor TraceExtractor
+	// TraceIDKey is the key the trace id is rendered under (default "trace_id").
+	TraceIDKey string
+	// SpanIDKey is the key the span id is rendered under (default "span_id").
+	SpanIDKey string
+	// TraceInPrefix renders the trace and span ids at the start of the prefix area instead of as a suffix.
+	TraceInPrefix bool
+	// PrefixPosition places the composed user/prefix either before the message (Left) or as a bracketed tag at the
+	// end of the header line (Right).
+	PrefixPosition Position
+	// MaxValueLength truncates string values (including those nested in structures) to the given number of
+	// characters, ending them with the Ellipsis (0 doesn't truncate).
+	MaxValueLength int
+	// NiceTimeTypes renders `time.Duration` values in their short form (e.g. "1.5s") and `time.Time` values using
+	// TimeValueFormat.
+	NiceTimeTypes bool
+	// TimeValueFormat is the layout for `time.Time` values when NiceTimeTypes is set (defaults to `time.RFC3339`).
+	TimeValueFormat string
+	// BytesMode sets how `[]byte` values are rendered.
+	BytesMode BytesMode
+	// Ellipsis is the marker used wherever content is truncated or elided (defaults to "…").
+	Ellipsis string
+	// CallerFirst places the caller (when the logger reports callers) at the start of the line rather than after
+	// the level.
+	CallerFirst bool
+	// CallerTrimPrefix is removed from the start of the caller's file path.
+	CallerTrimPrefix string
+	// CallerTrimPackage trims the import path from the caller's function name.
+	CallerTrimPackage bool
+	// CallerFormatter, if set, renders the caller text instead of CallerFormat.
+	CallerFormatter func(*runtime.Frame) string
+	// CallerFormat is a template for the caller text, which may contain `{function}`, `{file}` and `{line}`
+	// (defaults to `DefaultCallerFormat`).
+	CallerFormat string
+	// DebugColor renders colours as readable tags (`<green>text</green>`) instead of escape sequences, giving stable
+	// output of the coloured layout for tests.
+	DebugColor bool
+	// ColorLevels, when non-nil, restricts colour output to entries at the listed levels. Other entries are
+	// rendered plain (but with the same layout), even on a terminal.
+	ColorLevels []logrus.Level
+	// Style selects the overall output format, such as `StyleSyslog`. Most layout options only apply to the default.
+	Style Style
+	// SyslogFacility is the facility used in the priority of `StyleSyslog` messages (default 1, user-level).
+	SyslogFacility int
+	// SyslogAppName is the APP-NAME of `StyleSyslog` messages (default the program's name).
+	SyslogAppName string
+	// SyslogSDID is the SD-ID for the data fields of `StyleSyslog` messages (default `DefaultSyslogSDID`).
+	SyslogSDID string
+	// NonTerminalJSON renders each entry as a single line json object (with time, level, msg and the data fields)
+	// when the output isn't a terminal, for ingestion by log collectors. Most layout options don't apply to it.
+	NonTerminalJSON bool
+	// FlattenNewlines places the message on the log line with line breaks escaped (as `\n`) when the output isn't a
+	// terminal, so every entry is a single line. Data keys are always quoted there if they contain spaces or `=`.
+	FlattenNewlines bool
+	// ErrorKey is the data key holding an error (defaults to logrus's "error"), which is rendered as its message.
+	ErrorKey string
+	// ShowErrorStack adds an indented block below the ErrorKey's message (in the terminal layout) with the error's
+	// stack trace, if it carries one, or its chain of wrapped causes.
+	ShowErrorStack bool
+	// SQLKeys are the data keys holding sql queries (defaults to DefaultSQLKeys), which are reflowed a clause per
+	// line with their keywords highlighted in the terminal layout.
+	SQLKeys []string
+	// SQLArgsKey is the data key holding a query's bound arguments (defaults to "args"), which are shown with it.
+	SQLArgsKey string
+	// StackKey is the data key holding a stack trace (defaults to "stack"), as a Go stack trace string or a
+	// `[]runtime.Frame`, which is rendered a frame per line in the terminal layout.
+	StackKey string
+	// StackTopFrames is the number of frames at the top of the stack trace shown in the theme's StackTop style, with
+	// the rest dimmed (defaults to DefaultStackTopFrames, < 0 for none).
+	StackTopFrames int
+	// KeySort sets how data keys are sorted after any priority ordering.
+	KeySort KeySort
+	// KeySortFunc, if set, is used to sort data keys (after any priority ordering) instead of KeySort.
+	KeySortFunc func(a, b string) bool
 	// Ordering provides a priority order for data keys (higher numbers appear earlier, < 0 come after unprioritised)
 	Ordering map[string]int
 
-	isTerminal bool
-	jsonFmt    *prettyjson.Formatter
+	sequence  uint64
+	separated uint64
+	envColour int
+	envLinks  bool
+	jsonFmt   *prettyjson.Formatter
+	design    *depict.Design
+	plainFmt  *prettyjson.Formatter
 
-	sync.Once
+	dedupMu    sync.Mutex
+	dedupSig   uint64
+	dedupSeen  int
+	dedupStart time.Time
+	verboseMu  sync.Mutex
+	verbose    map[uint64]*verboseWindow
+
+	mu         sync.RWMutex
+	redaction  *redaction
+	filter     *fieldFilter
+	renderers  map[reflect.Type]Renderer
+	interfaces []reflect.Type
+	tees       []*tee
+	renames    map[string]string
+	highlights []highlight
+	keyStyles  map[string]string
+	rules      map[string][]ColorRule
+	overrides  map[logrus.Level]*override
+	base       *Formatter
+	timeMu     sync.Mutex
+	lastTime   time.Time
+	once       sync.Once
+	termMu     sync.RWMutex
+	terminals  map[io.Writer]bool
+	paletteMu  sync.RWMutex
+	colours    *palette
 }
 
 // DefaultFormatter is a ready to use Formatter for use with logrus.
 var DefaultFormatter = New()
 
-// New will allow you to create a new formatter with reasonable defaults to customise.
-func New() *Formatter {
+// New will allow you to create a new formatter with reasonable defaults to customise, applying any options.
+// It panics if the options are invalid; use `Build` to get an error instead.
+func New(opts ...Option) *Formatter {
+	f, err := Build(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// defaults returns a formatter with the default options.
+func defaults() *Formatter {
 	return &Formatter{
-		LevelLetters:   3,
-		LevelUpper:     true,
-		CompactSimple:  true,
-		MessageAfter:   true,
-		CompactMessage: true,
+		LevelLetters:    3,
+		KeyMinWidth:     5,
+		KeyMaxWidth:     20,
+		LevelUpper:      true,
+		TimestampFormat: DefaultTimestampFormat,
+		CompactSimple:   true,
+		MessageAfter:    true,
+		MessagePrefix:   "  ",
+		CompactMessage:  true,
+		FixZeroTime:     true,
+		NiceNetTypes:    true,
+		NiceHTTPTypes:   true,
+		NiceTimeTypes:   true,
+		TimeValueFormat: time.RFC3339,
+		Ellipsis:        "…",
+		Theme:           DefaultTheme,
+		ErrorKey:        logrus.ErrorKey,
+		StackKey:        "stack",
+		SQLArgsKey:      "args",
+		UserKey:         "user",
+		PrefixKeys:      []string{"prefix", "rpc"},
+		PrefixJoiner:    "/",
+		TraceIDKey:      "trace_id",
+		SpanIDKey:       "span_id",
+		SyslogFacility:  1,
+		SyslogSDID:      DefaultSyslogSDID,
 	}
 }
 
-var reCompact = regexp.MustCompile(`\s*\n\s*`)
+var reEscape = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)?|.)?`)
+var reSGR = regexp.MustCompile(`^\x1b\[[0-9;]*m$`)
+var lineBreaks = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+var reToken = regexp.MustCompile(`\{([^{}\s]+)\}`)
 var bSpace = []byte{' '}
 var bNewline = []byte{'\n'}
+var bComma = []byte{','}
+var bSpaces = bytes.Repeat(bSpace, 64)
+
+var reservedKeys = map[string]bool{
+	"time":  true,
+	"level": true,
+	"msg":   true,
+}
+
+// isNumber reports whether the marshalled data is a json number (rather than, e.g., a value rendered as a string).
+func isNumber(data []byte) bool {
+	if len(data) > 0 && data[0] == '-' {
+		data = data[1:]
+	}
+	return len(data) > 0 && data[0] >= '0' && data[0] <= '9'
+}
+
+// groupDigits inserts thousands separators into the integer part of a marshalled number.
+// Numbers in scientific notation are returned unchanged.
+func groupDigits(data []byte) ([]byte, bool) {
+	if bytes.ContainsAny(data, "eE") {
+		return data, false
+	}
+
+	start := 0
+	if len(data) > 0 && data[0] == '-' {
+		start = 1
+	}
+	end := bytes.IndexByte(data, '.')
+	if end < 0 {
+		end = len(data)
+	}
+
+	digits := end - start
+	if digits <= 3 {
+		return data, false
+	}
+
+	grouped := make([]byte, 0, len(data)+digits/3)
+	grouped = append(grouped, data[:start]...)
+	for i := start; i < end; i++ {
+		if i > start && (end-i)%3 == 0 {
+			grouped = append(grouped, bComma...)
+		}
+		grouped = append(grouped, data[i])
+	}
+	grouped = append(grouped, data[end:]...)
+	return grouped, true
+}
+
+// reshape decodes the json data, transforms it with fn and re-encodes the result.
+func reshape(data []byte, fn func(interface{}) interface{}) ([]byte, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return data, err
+	}
+	return json.Marshal(fn(v))
+}
+
+// truncateStrings shortens any strings within the decoded json value to at most max characters (including the
+// ellipsis), so the json remains valid.
+func truncateStrings(v interface{}, max int, ellipsis string) interface{} {
+	switch t := v.(type) {
+	case string:
+		r := []rune(t)
+		if len(r) <= max || t == Redacted {
+			return t
+		}
+		keep := max - utf8.RuneCountInString(ellipsis)
+		if keep < 0 {
+			keep = 0
+		}
+		return string(r[:keep]) + ellipsis
+	case map[string]interface{}:
+		for k, c := range t {
+			t[k] = truncateStrings(c, max, ellipsis)
+		}
+	case []interface{}:
+		for i, c := range t {
+			t[i] = truncateStrings(c, max, ellipsis)
+		}
+	}
+	return v
+}
+
+// elide replaces structures nested deeper than depth with placeholders.
+func elide(v interface{}, depth int, ellipsis string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(t) == 0 {
+			return t
+		}
+		if depth <= 0 {
+			return "{" + ellipsis + "}"
+		}
+		for k, c := range t {
+			t[k] = elide(c, depth-1, ellipsis)
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			return t
+		}
+		if depth <= 0 {
+			return "[" + ellipsis + "]"
+		}
+		for i, c := range t {
+			t[i] = elide(c, depth-1, ellipsis)
+		}
+	}
+	return v
+}
+
+// summarise replaces the maps and arrays nested deeper than depth with a count of their contents.
+func summarise(v interface{}, depth int, ellipsis string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if depth <= 0 {
+			return fmt.Sprintf("{%s%d %s}", ellipsis, len(t), plural(len(t), "field", "fields"))
+		}
+		for k, c := range t {
+			t[k] = summarise(c, depth-1, ellipsis)
+		}
+	case []interface{}:
+		if depth <= 0 {
+			return fmt.Sprintf("[%s%d %s]", ellipsis, len(t), plural(len(t), "item", "items"))
+		}
+		for i, c := range t {
+			t[i] = summarise(c, depth-1, ellipsis)
+		}
+	}
+	return v
+}
+
+// limitElements cuts arrays and maps down to max elements, adding a note of how many were removed.
+func limitElements(v interface{}, max int, ellipsis string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(t) > max {
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys[max:] {
+				delete(t, k)
+			}
+			t[ellipsis] = fmt.Sprintf("(+%d more)", len(keys)-max)
+		}
+		for k, c := range t {
+			t[k] = limitElements(c, max, ellipsis)
+		}
+	case []interface{}:
+		more := len(t) - max
+		if more > 0 {
+			t = t[:max]
+		}
+		for i, c := range t {
+			t[i] = limitElements(c, max, ellipsis)
+		}
+		if more > 0 {
+			return append(t, fmt.Sprintf("%s (+%d more)", ellipsis, more))
+		}
+		return t
+	}
+	return v
+}
+
+// plural picks the singular or plural form for the count.
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// onlySGR removes any escape sequences that aren't SGR (colour) sequences.
+func onlySGR(data []byte) []byte {
+	return reEscape.ReplaceAllFunc(data, func(seq []byte) []byte {
+		if reSGR.Match(seq) {
+			return seq
+		}
+		return nil
+	})
+}
+
+// stringify returns a plain string representation of a data value.
+func stringify(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case error:
+		return t.Error()
+	case fmt.Stringer:
+		return t.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// interpolate substitutes `{key}` tokens in the message with their data values, returning the keys it consumed.
+func (f *Formatter) interpolate(message string, data logrus.Fields, redactor *redaction) (string, map[string]bool) {
+	consumed := map[string]bool{}
+	message = reToken.ReplaceAllStringFunc(message, func(token string) string {
+		key := token[1 : len(token)-1]
+		v, ok := data[key]
+		if !ok {
+			return token
+		}
+		consumed[key] = true
+		if redactor.matches(key) {
+			return Redacted
+		}
+		return f.safeText(func() string {
+			return stringify(v)
+		})
+	})
+	return message, consumed
+}
+
+// encode returns the json representation of a data value using the built-in conversions.
+func (f *Formatter) encode(value interface{}) ([]byte, error) {
+	value = f.simplify(value)
+
+	if str, ok := nonFinite(value); ok {
+		return json.Marshal(str)
+	}
+
+	var portrayal *depict.Depiction
+	if f.design != nil {
+		portrayal = f.design.Portray(value)
+	} else {
+		portrayal = depict.Portray(value)
+	}
+	data, err := json.Marshal(portrayal)
+
+	if err == nil && len(data) == 2 && data[0] == '{' {
+		if v, ok := value.(error); ok {
+			str := v.Error()
+			if len(str) > 0 {
+				data, err = json.Marshal(str)
+			}
+		} else if v, ok := value.(fmt.Stringer); ok {
+			str := v.String()
+			if len(str) > 0 {
+				data, err = json.Marshal(str)
+			}
+		}
+	}
+
+	return data, err
+}
+
+// marshal returns the json representation of a data value, after any redaction and truncation.
+func (f *Formatter) marshal(value interface{}) (data []byte, err error) {
+	defer f.recoverValue(&data, &err, nil)
+	return f.marshalValue(value, f.redactor())
+}
+
+// marshalUnredacted is marshal without the redaction, for comparing values that may differ only in redacted keys.
+func (f *Formatter) marshalUnredacted(value interface{}) (data []byte, err error) {
+	defer f.recoverValue(&data, &err, nil)
+	return f.marshalValue(value, nil)
+}
+
+// marshalValue is marshal, with the given redaction rules, without the recovery from panics.
+func (f *Formatter) marshalValue(value interface{}, r *redaction) (data []byte, err error) {
+	value = resolve(value)
+	if p, ok := value.(panicked); ok {
+		return placeholder(p.r, nil), nil
+	}
+	if renderer := f.renderer(value); renderer != nil {
+		data, err = renderer(value)
+	} else if summary, ok := f.niceHTTP(value); ok {
+		data = summary
+	} else {
+		data, err = f.encode(value)
+	}
+
+	if err == nil && r != nil && len(data) > 0 && (data[0] == '{' || data[0] == '[') {
+		data, err = reshape(data, r.redact)
+	}
+
+	if err == nil && f.MaxValueLength > 0 {
+		max, ellipsis := f.MaxValueLength, f.ellipsis()
+		data, err = reshape(data, func(v interface{}) interface{} {
+			return truncateStrings(v, max, ellipsis)
+		})
+	}
+
+	if err == nil && f.PreviewDepth > 0 {
+		depth, ellipsis := f.PreviewDepth, f.ellipsis()
+		data, err = reshape(data, func(v interface{}) interface{} {
+			return elide(v, depth, ellipsis)
+		})
+	}
+
+	if err == nil && f.MaxDepth > 0 {
+		depth, ellipsis := f.MaxDepth, f.ellipsis()
+		data, err = reshape(data, func(v interface{}) interface{} {
+			return summarise(v, depth, ellipsis)
+		})
+	}
+
+	if err == nil && f.MaxElements > 0 {
+		max, ellipsis := f.MaxElements, f.ellipsis()
+		data, err = reshape(data, func(v interface{}) interface{} {
+			return limitElements(v, max, ellipsis)
+		})
+	}
+
+	return data, err
+}
+
+// render returns the display representation of a data value, pretty printed by jsonFmt if given.
+func (f *Formatter) render(value interface{}, jsonFmt *prettyjson.Formatter) (data []byte, err error) {
+	defer f.recoverValue(&data, &err, jsonFmt)
+	value = resolve(value)
+	if p, ok := value.(panicked); ok {
+		return placeholder(p.r, jsonFmt), nil
+	}
+	str, special := nonFinite(f.simplify(value))
+	if f.renderer(value) != nil {
+		special = false
+	}
+	if special && jsonFmt != nil {
+		if jsonFmt.DisabledColor {
+			return []byte(str), nil
+		}
+		return []byte(jsonFmt.NumberColor.Sprint(str)), nil
+	}
+
+	data, err = f.marshalValue(value, f.redactor())
+
+	// Set for numbers that have been formatted for display, and so aren't valid json.
+	number := false
+	if err == nil && !special && f.NumberGrouping && isNumber(data) {
+		data, number = groupDigits(data)
+	}
+
+	if err == nil && jsonFmt != nil {
+		if number {
+			if !jsonFmt.DisabledColor {
+				data = []byte(jsonFmt.NumberColor.Sprint(string(data)))
+			}
+		} else if pretty, pErr := jsonFmt.Format(data); pErr == nil {
+			data = pretty
+		}
+	}
+
+	return data, err
+}
+
+// DefaultCompactThreshold is the CompactThreshold used when none is set.
+const DefaultCompactThreshold = 100
+
+// CompactTerminal is a `CompactThreshold` that compacts structures when they fit within the terminal.
+const CompactTerminal = -1
+
+// compactFits returns a function that reports whether rendered data is small enough to compact, when written at the
+// column.
+func (f *Formatter) compactFits(entry *logrus.Entry, column int) func(data []byte) bool {
+	threshold := f.CompactThreshold
+	if threshold == CompactTerminal {
+		if width := f.terminalWidth(entry); width > column {
+			return func(data []byte) bool {
+				var line bytes.Buffer
+				writeCompact(&line, data)
+				return visibleWidth(line.Bytes()) <= width-column
+			}
+		}
+		threshold = 0
+	}
+	if threshold <= 0 {
+		threshold = DefaultCompactThreshold
+	}
+	return func(data []byte) bool {
+		return len(data) < threshold
+	}
+}
+
+// compact decides whether a rendered data value should be placed on a single line.
+func (f *Formatter) compact(key string, data []byte, fits func([]byte) bool) bool {
+	for _, k := range f.CompactKeys {
+		if k == key {
+			return true
+		}
+	}
+	for _, k := range f.ExpandKeys {
+		if k == key {
+			return false
+		}
+	}
+	return f.CompactFull || (f.CompactSimple && fits(data))
+}
+
+// ellipsis returns the truncation marker.
+func (f *Formatter) ellipsis() string {
+	if f.Ellipsis == "" {
+		return "…"
+	}
+	return f.Ellipsis
+}
+
+// envColour checks the `NO_COLOR`, `CLICOLOR_FORCE` and `CLICOLOR` environment variables, returning < 0 if colour
+// has been disabled, > 0 if it has been forced, or 0 to leave it to terminal detection.
+func envColour() int {
+	if os.Getenv("NO_COLOR") != "" {
+		return -1
+	}
+	if v := os.Getenv("CLICOLOR_FORCE"); v != "" && v != "0" {
+		return 1
+	}
+	if os.Getenv("CLICOLOR") == "0" {
+		return -1
+	}
+	return 0
+}
+
+// colourFunc returns a function that applies the ansi style to a string.
+func (f *Formatter) colourFunc(style string) func(string) string {
+	if f.DebugColor {
+		return func(s string) string {
+			return "<" + style + ">" + s + "</" + style + ">"
+		}
+	}
+	return f.ansiColourFunc(style)
+}
+
+// quoteKey quotes a key for inline `key=value` output if it contains characters that would make it ambiguous.
+func quoteKey(key string) string {
+	if key == "" || strings.IndexFunc(key, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+	}) >= 0 {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+// headerKey reports whether the data key is rendered as part of the prefix.
+func (f *Formatter) headerKey(key string) bool {
+	if key == f.UserKey {
+		return true
+	}
+	for _, k := range f.PrefixKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleWidth returns the number of characters in the text, ignoring escape sequences.
+func visibleWidth(text []byte) int {
+	return utf8.RuneCount(reEscape.ReplaceAll(text, nil))
+}
+
+// indentLines indents any continuation lines of the text by column spaces, followed by the gutter.
+func indentLines(text string, column int, gutter string) string {
+	if !strings.Contains(text, "\n") {
+		return text
+	}
+	return strings.Replace(text, "\n", "\n"+strings.Repeat(" ", column)+gutter, -1)
+}
+
+// colourLines applies the colour to each line of the text separately, leaving the line breaks (and so any
+// indentation added after them) uncoloured.
+func colourLines(text string, colour func(string) string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = colour(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isSpace reports whether the character is whitespace (as matched by `\s`).
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// writeCompact writes the data with each line break (and the whitespace around it) collapsed into a single space.
+func writeCompact(b *bytes.Buffer, data []byte) {
+	start := 0
+	for i := 0; i < len(data); {
+		if !isSpace(data[i]) {
+			i++
+			continue
+		}
+		j, newline := i, false
+		for ; j < len(data) && isSpace(data[j]); j++ {
+			newline = newline || data[j] == '\n'
+		}
+		if newline {
+			b.Write(data[start:i])
+			b.WriteByte(' ')
+			start = j
+		}
+		i = j
+	}
+	b.Write(data[start:])
+}
+
+// compactString collapses the line breaks in a string, as per writeCompact.
+func compactString(s string) string {
+	if strings.IndexByte(s, '\n') < 0 {
+		return s
+	}
+	var b bytes.Buffer
+	writeCompact(&b, []byte(s))
+	return b.String()
+}
+
+// writeSpaces writes n spaces without allocating.
+func writeSpaces(b *bytes.Buffer, n int) {
+	for ; n > len(bSpaces); n -= len(bSpaces) {
+		b.Write(bSpaces)
+	}
+	if n > 0 {
+		b.Write(bSpaces[:n])
+	}
+}
+
+// writeIndented writes the data with every line after the first indented to the column.
+func writeIndented(b *bytes.Buffer, data []byte, column int) {
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			b.Write(data)
+			return
+		}
+		b.Write(data[:i+1])
+		writeSpaces(b, column)
+		data = data[i+1:]
+	}
+}
+
+// writeLabel starts a line of the block layout with the label, padded to the key width according to KeyAlign.
+func (f *Formatter) writeLabel(b *bytes.Buffer, label string, keySize int, colour func(string) string) {
+	pad := keySize - utf8.RuneCountInString(label)
+	b.Write(bNewline)
+	b.WriteString("  ")
+	if f.KeyAlign == Right {
+		writeSpaces(b, pad)
+	}
+	b.WriteString(colour(label))
+	b.WriteString(": ")
+	if f.KeyAlign != Right {
+		writeSpaces(b, pad)
+	}
+}
+
+// diffLabel returns the display name for a data key, which for `DiffPairs` includes the after key.
+func (f *Formatter) diffLabel(key string, diffs map[string]string) string {
+	if after, ok := diffs[key]; ok {
+		return f.label(key) + "→" + f.label(after)
+	}
+	return f.label(key)
+}
+
+// label returns the display name for a data key.
+func (f *Formatter) label(key string) string {
+	if f.PrefixCollidingKeys && reservedKeys[key] {
+		return "data." + key
+	}
+	return key
+}
 
 // Order adds a priority to a given list of keys (chainable call).
+// It is safe to call while the formatter is in use, as the ordering is replaced rather than modified in place.
 func (f *Formatter) Order(priority int, keys ...string) *Formatter {
-	if f.Ordering == nil {
-		f.Ordering = map[string]int{}
-	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
+	ordering := make(map[string]int, len(f.Ordering)+len(keys))
+	for key, p := range f.Ordering {
+		ordering[key] = p
+	}
 	for _, key := range keys {
-		f.Ordering[key] = priority
+		ordering[key] = priority
 	}
+	f.Ordering = ordering
 
 	return f
 }
 
 // Format takes a logrus Entry and renders it into a byte slice.
 func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
-	f.Do(func() {
-		if entry.Logger != nil {
-			switch v := entry.Logger.Out.(type) {
-			case *os.File:
-				f.isTerminal = terminal.IsTerminal(int(v.Fd()))
-			}
+	return f.format(entry, false)
+}
+
+// format renders the entry. Standalone entries (from Sprint) aren't part of the logged stream, so aren't tee'd, and
+// neither use nor change the state of the deduplication, rate limiting, sequence numbers, separators or time deltas.
+func (f *Formatter) format(entry *logrus.Entry, standalone bool) ([]byte, error) {
+	if o := f.override(entry.Level); o != nil {
+		return o.format(entry, standalone)
+	}
+
+	f.once.Do(func() {
+		f.envColour = envColour()
+		f.envLinks = hyperlinks()
+		if f.MaxDepth > 0 {
+			// Allow some headroom, as depict counts a level for each interface as well as each structure, and we want our
+			// summaries at the limit.
+			f.design = depict.New(depict.MaxDepth(2*f.MaxDepth + 2))
 		}
+		theme := f.Theme.merged()
 		f.jsonFmt = prettyjson.NewFormatter()
 		f.jsonFmt.Indent = 1
+		f.jsonFmt.KeyColor = jsonColour(theme.JSONKey, f.colourDepth())
+		f.jsonFmt.StringColor = jsonColour(theme.JSONString, f.colourDepth())
+		f.jsonFmt.BoolColor = jsonColour(theme.JSONBool, f.colourDepth())
+		f.jsonFmt.NumberColor = jsonColour(theme.JSONNumber, f.colourDepth())
+		f.jsonFmt.NullColor = jsonColour(theme.JSONNull, f.colourDepth())
+		f.plainFmt = prettyjson.NewFormatter()
+		f.plainFmt.Indent = 1
+		f.plainFmt.DisabledColor = true
+		// We make our own decision about colour, so don't let the color package second guess it based on stdout.
+		for _, c := range []*color.Color{
+			f.jsonFmt.KeyColor,
+			f.jsonFmt.StringColor,
+			f.jsonFmt.BoolColor,
+			f.jsonFmt.NumberColor,
+			f.jsonFmt.NullColor,
+		} {
+			c.EnableColor()
+		}
 	})
 
-	var levelColour func(string) string
+	if f.FieldHook != nil {
+		if fields := f.FieldHook(entry); len(fields) > 0 {
+			entry = withData(entry, fields)
+		}
+	}
+
+	if !standalone {
+		if err := f.writeTees(entry); err != nil && f.Strict {
+			return nil, fmt.Errorf("formatrus: unable to write tee: %v", err)
+		}
+	}
+
+	if data, renamed := f.renamed(entry.Data); renamed || f.GroupDottedKeys || f.FlattenNested {
+		e := *entry
+		e.Data = data
+		if f.GroupDottedKeys {
+			e.Data = groupDotted(data, f.redactor())
+		} else if f.FlattenNested {
+			e.Data = f.flattenNested(data)
+		}
+		entry = &e
+	}
+
+	// The terminal layout is used whenever colour is possible, even if this entry's level isn't coloured.
+	terminal := f.ForceColor || f.ForceColors || f.FileColor || f.DebugColor
+	if !f.Deterministic {
+		terminal = terminal || f.outputIsTerminal(entry) || f.envColour > 0
+	}
+	colour := terminal && f.envColour >= 0 && !f.DisableColors && !f.Deterministic && f.colourLevel(entry.Level)
+
 	var levelText string
 	var levelText3 string
 	var levelText5 string
 	switch entry.Level {
 	case logrus.InfoLevel:
-		levelColour = green
 		levelText3 = "Inf"
 		levelText5 = "Info "
 	case logrus.WarnLevel:
-		levelColour = yellow
 		levelText3 = "Wrn"
 		levelText5 = "Warn "
 	case logrus.ErrorLevel:
-		levelColour = red
 		levelText3 = "Err"
 		levelText5 = "Error"
 	case logrus.FatalLevel:
-		levelColour = red
 		levelText3 = "Ftl"
 		levelText5 = "Fatal"
 	case logrus.PanicLevel:
-		levelColour = red
 		levelText3 = "Pnc"
 		levelText5 = "Panic"
+	case logrus.TraceLevel:
+		levelText3 = "Trc"
+		levelText5 = "Trace"
 	default:
-		levelColour = blue
 		levelText3 = "Dbg"
 		levelText5 = "Debug"
 	}
 
-	dataColour := cyan
-	prefixColour := magenta
-	userColour := whiteH
-	timeColour := blackH
+	palette := f.palette()
+	theme := palette.theme
+	levelStyle := theme.level(entry.Level)
+	if c, ok := f.LevelColors[entry.Level]; ok {
+		levelStyle = c
+	}
+	severe := f.atLeast(entry.Level, logrus.FatalLevel)
+	if severe && f.EmphasiseSevere {
+		levelStyle = theme.Emphasis
+	}
+
+	messageColour := noColour
+	if f.ColorMessage {
+		// Emphasis is meant for the short level text, not the whole message.
+		messageStyle := theme.level(entry.Level)
+		if c, ok := f.LevelColors[entry.Level]; ok {
+			messageStyle = c
+		}
+		messageColour = palette.colour(messageStyle)
+	}
+	levelColour := palette.colour(levelStyle)
+	dataColour := palette.colour(theme.Key)
+	prefixColour := palette.colour(theme.Prefix)
+	userColour := palette.colour(theme.User)
+	timeColour := palette.colour(theme.Time)
+	dimColour := palette.colour(theme.Dim)
+	callerColour := palette.colour(theme.Caller)
+	bannerColour := palette.colour(theme.Banner)
+	stackColour := palette.colour(theme.StackTop)
+	keywordColour := palette.colour(theme.Keyword)
+	jsonFmt := f.jsonFmt
+	if f.DebugColor {
+		jsonFmt = f.plainFmt
+	}
 
-	if !f.isTerminal {
+	if !colour {
 		levelColour = noColour
+		messageColour = noColour
 		dataColour = noColour
 		prefixColour = noColour
 		userColour = noColour
+		timeColour = noColour
+		dimColour = noColour
+		callerColour = noColour
+		bannerColour = noColour
+		stackColour = noColour
+		keywordColour = noColour
+		jsonFmt = f.plainFmt
+	}
+	if !terminal {
 		timeColour = braketise
+		jsonFmt = nil
 	}
 
+	// Logrus provides (and reuses) a buffer for each entry, but otherwise we use a pooled one and return a copy of its
+	// bytes.
 	b := entry.Buffer
-	if b == nil {
-		b = &bytes.Buffer{}
+	pooled := b == nil
+	if pooled {
+		b = getBuffer()
+		defer putBuffer(b)
+	}
+
+	if f.Style == StyleSyslog {
+		if err := f.formatSyslog(entry, b); err != nil {
+			return nil, err
+		}
+		return f.finish(b.Bytes(), pooled), nil
 	}
 
-	if f.LevelLetters <= 0 {
-		f.LevelLetters = 3
+	if f.NonTerminalJSON && !terminal && !f.Minimal {
+		if err := f.formatJSON(entry, b); err != nil {
+			return nil, err
+		}
+		return f.finish(f.journal(entry.Level, b.Bytes()), pooled), nil
 	}
 
-	if f.LevelLetters >= 5 {
+	if f.Deduplicate && !standalone {
+		repeat, flushed := f.dedup(entry)
+		if repeat {
+			return nil, nil
+		}
+		if flushed > 0 {
+			fmt.Fprintf(b, "%s\n", dimColour(fmt.Sprintf("(repeated %d times)", flushed)))
+		}
+	}
+	oneLine := f.OneLine || f.Minimal || (!standalone && !f.verboseAllowed(entry)) || f.simplified(entry.Level)
+	if f.Separator != "" && terminal && !standalone {
+		f.writeSeparator(b, entry, dimColour)
+	}
+	banner := ""
+	if severe && f.SevereBanner && terminal {
+		banner = bannerColour(strings.Repeat("━", f.lineWidth(entry)))
+		fmt.Fprintln(b, banner)
+	}
+	lineStart := b.Len()
+
+	letters := f.LevelLetters
+	if letters <= 0 {
+		letters = 3
+	}
+
+	if letters >= 5 {
 		levelText = levelText5
-	} else if f.LevelLetters > 3 {
-		levelText = levelText5[0:f.LevelLetters]
+	} else if letters > 3 {
+		levelText = levelText5[0:letters]
 	} else {
-		levelText = levelText3[0:f.LevelLetters]
+		levelText = levelText3[0:letters]
 	}
 
-	if f.LevelUpper {
-		levelText = strings.ToUpper(levelText)
+	levelText = f.levelCase().apply(levelText)
+	if text, ok := f.customLevelText(entry.Level); ok {
+		levelText = text
 	}
-	if f.LevelLower {
-		levelText = strings.ToUpper(levelText)
+	if icon := f.levelIcon(entry.Level); icon != "" && terminal {
+		switch f.LevelIcons {
+		case IconsAlongside:
+			levelText = icon + " " + levelText
+		case IconsOnly:
+			levelText = icon
+		}
 	}
 
+	redactor := f.redactor()
 	user := ""
 	prefix := ""
 
-	if v, ok := entry.Data["user"]; ok {
+	if v, ok := entry.Data[f.UserKey]; ok && f.UserKey != "" {
 		if v, ok := v.(string); ok {
+			if redactor.matches(f.UserKey) {
+				v = Redacted
+			}
 			user = userColour(v + "@")
 		}
 	}
-	if v, ok := entry.Data["rpc"]; ok {
-		if v, ok := v.(string); ok {
-			prefix = v
+	for _, key := range f.PrefixKeys {
+		if v, ok := entry.Data[key]; ok {
+			if v, ok := v.(string); ok && v != "" {
+				if redactor.matches(key) {
+					v = Redacted
+				}
+				if prefix != "" {
+					prefix += f.PrefixJoiner
+				}
+				prefix += v
+			}
 		}
 	}
-	if v, ok := entry.Data["prefix"]; ok {
-		if v, ok := v.(string); ok {
-			if prefix != "" {
-				v = v + "/"
-			}
-			prefix = v + prefix
+	tag := ""
+	if f.PrefixPosition == Right {
+		if prefix != "" {
+			prefix = prefixColour(prefix)
+		}
+		if user != "" || prefix != "" {
+			tag = braketise(user + prefix)
+		}
+		prefix = ""
+	} else {
+		if prefix != "" {
+			prefix = prefixColour(prefix + ":")
+		}
+		prefix = user + prefix
+		if prefix != "" {
+			prefix += " "
 		}
 	}
-	if prefix != "" {
-		prefix = prefixColour(prefix + ":")
+	if traceID, spanID := f.traceIDs(entry); traceID != "" || spanID != "" {
+		text := dimColour(f.traceText(traceID, spanID))
+		if f.TraceInPrefix {
+			prefix = text + " " + prefix
+		} else if tag != "" {
+			tag += " " + text
+		} else {
+			tag = text
+		}
 	}
-	prefix = user + prefix
-	if prefix != "" {
-		prefix += " "
+	headed := prefix != "" || tag != ""
+
+	when := entry.Time
+	if f.FixZeroTime && when.IsZero() {
+		when = time.Now()
+	}
+
+	if f.ShowSequence && !standalone {
+		fmt.Fprintf(b, "%s ", dimColour(fmt.Sprintf("#%d", atomic.AddUint64(&f.root().sequence, 1))))
+	}
+
+	caller := ""
+	if entry.Caller != nil && f.keyVisible(f.fieldFilter(), "caller", entry.Level) {
+		caller = callerColour(f.callerText(entry.Caller))
+	}
+	if caller != "" && f.CallerFirst {
+		fmt.Fprintf(b, "%s ", caller)
 	}
 
-	fmt.Fprintf(b, "%s %s",
-		timeColour(entry.Time.Format("Jan 02 15:04:05.000")),
-		levelColour(levelText),
-	)
+	timeText := timeColour(f.moment(when, !standalone))
+	levelText = levelColour(levelText)
+	if f.DisableTimestamp || f.JournaldPriority || f.Minimal || f.Deterministic {
+		fmt.Fprint(b, levelText)
+	} else if f.LevelFirst {
+		fmt.Fprintf(b, "%s %s", levelText, timeText)
+	} else {
+		fmt.Fprintf(b, "%s %s", timeText, levelText)
+	}
+
+	if caller != "" && !f.CallerFirst {
+		fmt.Fprintf(b, " %s", caller)
+	}
 
 	if prefix != "" {
 		fmt.Fprintf(b, " %s", prefix)
 	}
 
+	filter := f.fieldFilter()
+	var highlights []highlight
+	if colour {
+		highlights = f.highlighter()
+	}
+	linking := f.Hyperlinks && colour && f.envLinks
+
+	message := entry.Message
+	var consumed map[string]bool
+	if f.InterpolateMessage {
+		message, consumed = f.interpolate(message, entry.Data, redactor)
+	}
+
 	var orders []string
+	showFields := f.FieldsMinLevel == nil || f.atLeast(entry.Level, *f.FieldsMinLevel)
+	var diffs map[string]string
+	if terminal && !oneLine {
+		diffs = f.diffPairs(entry.Data, redactor)
+	}
 
-	keySize := 5
-	keys := make([]string, 0, len(entry.Data))
+	scratch := getKeys()
+	defer putKeys(scratch)
+	keys := *scratch
 	for key, v := range entry.Data {
-		if key == "_order" {
-			orders = v.([]string)
+		if key == OrderKey {
+			if o, ok := v.([]string); ok {
+				orders = o
+			} else if f.Strict {
+				return nil, fmt.Errorf("formatrus: %s must be a []string, not %T", OrderKey, v)
+			}
 			continue
 		}
-		if (key == "prefix" || key == "rpc" || key == "user") && prefix != "" {
+		if headed && f.headerKey(key) {
+			continue
+		}
+		if consumed[key] || !f.keyVisible(filter, key, entry.Level) || !showFields || isDiffAfter(diffs, key) {
 			continue
 		}
 		keys = append(keys, key)
-		if n := len(key); n > keySize {
+	}
+
+	// Visible args are shown with a visible query, rather than on their own.
+	sql := false
+	if terminal && !oneLine && f.SQLArgsKey != "" {
+		for i, key := range keys {
+			if key == f.SQLArgsKey && f.hasSQL(keys, entry.Data) {
+				keys = append(keys[:i], keys[i+1:]...)
+				sql = true
+				break
+			}
+		}
+	}
+
+	keySize := f.KeyMinWidth
+	for _, key := range keys {
+		if n := utf8.RuneCountInString(f.diffLabel(key, diffs)); n > keySize {
 			keySize = n
 		}
 	}
 
-	if f.Ordering == nil && len(orders) == 0 {
-		sort.Strings(keys)
+	*scratch = keys
+
+	f.mu.RLock()
+	ordering := f.Ordering
+	f.mu.RUnlock()
+
+	less := f.keyLess()
+	if ordering == nil && len(orders) == 0 {
+		if less == nil {
+			sort.Strings(keys)
+		} else {
+			sort.Slice(keys, func(i, j int) bool {
+				return less(keys[i], keys[j])
+			})
+		}
 	} else {
 		var pri map[string]int
 		if len(orders) > 0 {
 			pri = map[string]int{}
+			// Listed keys sort before the unlisted ones, which all have a priority of 0.
 			for i, k := range orders {
-				pri[k] = i
+				pri[k] = i - len(orders)
 			}
 		}
 
 		s := &sorter{
-			order: f.Ordering,
+			order: ordering,
 			keys:  keys,
 			pri:   pri,
+			less:  less,
 		}
 		sort.Sort(s)
 	}
 
-	if keySize > 20 {
-		keySize = 20
+	if max := f.KeyMaxWidth; max > 0 && keySize > max {
+		keySize = max
+	}
+	if f.FixedKeyWidth > 0 {
+		keySize = f.FixedKeyWidth
+	}
+
+	flatten := f.FlattenNewlines && !terminal
+	if flatten {
+		message = lineBreaks.Replace(message)
+	} else if oneLine {
+		message = compactString(message)
 	}
 
 	// We can cuddle if we haven't been told to put the message after, or if we've been told we can cuddle, and there's
-	// no keys to print and the message isn't overly long.
-	cuddleMessage := !f.MessageAfter || (f.CompactMessage && len(keys) == 0 && len(entry.Message) < 100)
-	if cuddleMessage {
-		fmt.Fprint(b, entry.Message)
+	// no keys to print and the message isn't overly long (or we don't mind long ones).
+	cuddleMessage := oneLine || flatten || !f.MessageAfter ||
+		(f.CompactMessage && len(keys) == 0 && (len(message) < 100 || f.CuddleLongMessages))
+	width := 0
+	if terminal && !oneLine {
+		width = f.wrapWidth(entry)
+	}
+	if cuddleMessage && message != "" {
+		// Separate the message from the level (a prefix already ends with a space).
+		if prefix == "" {
+			b.Write(bSpace)
+		}
+		column := visibleWidth(b.Bytes()[lineStart:])
+		text := message
+		if width > 0 {
+			text = wrap(text, width-column-visibleWidth([]byte(f.MessageGutter)))
+		}
+		text = colourLines(text, messageColour)
+		if linking {
+			text = linkURLs(text)
+		}
+		text = string(applyHighlights([]byte(text), highlights))
+		fmt.Fprint(b, indentLines(text, column, f.MessageGutter))
+	}
+	colours := diffColours{
+		add:    palette.colour(theme.DiffAdd),
+		remove: palette.colour(theme.DiffRemove),
+		change: palette.colour(theme.DiffChange),
+	}
+	if !colour {
+		colours = diffColours{noColour, noColour, noColour}
+	}
+
+	var keyColours map[string]func(string) string
+	var rules map[string][]ColorRule
+	if colour {
+		keyColours = f.keyColours()
+		rules = f.valueRules()
+	}
+
+	indent := keySize + 4
+	fits := f.compactFits(entry, indent)
+
+	// fieldValue returns the value of the key to display, and the rules for colouring it.
+	fieldValue := func(key string) (interface{}, []ColorRule) {
+		if redactor.matches(key) {
+			return Redacted, nil
+		}
+		return f.resolved(entry.Data[key]), rules[key]
+	}
+	// keyColourFor returns the colour for the key.
+	keyColourFor := func(key string) func(string) string {
+		if c, ok := keyColours[key]; ok {
+			return c
+		}
+		return dataColour
+	}
+	// renderValue renders the field's value, with any of its colour rules, links and highlights.
+	renderValue := func(key string, value interface{}, keyRules []ColorRule) ([]byte, error) {
+		valueFmt := jsonFmt
+		var valueColour func(string) string
+		if style := valueStyle(keyRules, value); style != "" {
+			valueFmt = f.plainFmt
+			valueColour = f.colourFunc(style)
+		}
+
+		data, err := f.render(value, valueFmt)
+		if err != nil {
+			if f.Strict {
+				return nil, fmt.Errorf("formatrus: unable to render field %q: %v", key, err)
+			}
+			data = []byte(fmt.Sprintf("%#v", data))
+		}
+		if valueColour != nil {
+			data = []byte(colourLines(string(data), valueColour))
+		}
+		if linking {
+			if v, ok := value.(string); ok && f.isLinkKey(key) {
+				data = []byte(link(linkTarget(v), string(data)))
+			} else {
+				data = []byte(linkURLs(string(data)))
+			}
+		}
+		return applyHighlights(data, highlights), nil
+	}
+
+	if f.InlineFields && terminal && !oneLine {
+		// Short fields join the header line, leaving the rest for the block below it.
+		block := keys[:0]
+		for _, key := range keys {
+			value, keyRules := fieldValue(key)
+			if _, ok := diffs[key]; ok || !f.inlineable(key, value) {
+				block = append(block, key)
+				continue
+			}
+			if v, ok := value.(error); ok && key == f.ErrorKey {
+				value = f.errorText(v)
+			}
+			data, err := renderValue(key, value, keyRules)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.IndexByte(data, '\n') >= 0 || !fits(data) {
+				block = append(block, key)
+				continue
+			}
+			b.WriteByte(' ')
+			b.WriteString(keyColourFor(key)(quoteKey(f.label(key))))
+			b.WriteByte('=')
+			b.Write(data)
+		}
+		keys = block
+	}
+
+	// The tag goes at the end of the header line, which is after the data when it's rendered inline.
+	inlineData := oneLine || !terminal
+	if tag != "" && !inlineData {
+		fmt.Fprintf(b, " %s", tag)
+	}
+	trailer := f.trailer(entry)
+	trailerWidth := width
+	if trailerWidth <= 0 && terminal {
+		trailerWidth = f.terminalWidth(entry)
+	}
+	if trailer != "" && !inlineData {
+		writeTrailer(b, dimColour(trailer), trailerWidth)
 	}
 
-	padding := []byte(fmt.Sprintf("\n%s", string(bytes.Repeat([]byte{' '}, keySize+4))))
 	for _, key := range keys {
-		value := entry.Data[key]
+		value, keyRules := fieldValue(key)
+		keyColour := keyColourFor(key)
 
-		data, err := json.Marshal(depict.Portray(value))
+		if after, ok := diffs[key]; ok {
+			data, err := f.diff(value, entry.Data[after], colours)
+			if err == nil {
+				f.writeLabel(b, f.diffLabel(key, diffs), keySize, keyColour)
+				writeIndented(b, data, indent)
+				continue
+			}
+			if f.Strict {
+				return nil, fmt.Errorf("formatrus: unable to diff field %q: %v", key, err)
+			}
+		}
 
-		if err == nil && len(data) == 2 && data[0] == '{' {
-			if v, ok := value.(error); ok {
-				str := v.Error()
-				if len(str) > 0 {
-					data, err = json.Marshal(str)
+		if q, ok := value.(string); ok && f.isSQLKey(key) && terminal && !oneLine {
+			var args []byte
+			if v, ok := entry.Data[f.SQLArgsKey]; ok && sql {
+				if redactor.matches(f.SQLArgsKey) {
+					v = Redacted
 				}
-			} else if v, ok := value.(fmt.Stringer); ok {
-				str := v.String()
-				if len(str) > 0 {
-					data, err = json.Marshal(str)
+				var err error
+				if args, err = f.render(v, jsonFmt); err != nil && f.Strict {
+					return nil, fmt.Errorf("formatrus: unable to render field %q: %v", f.SQLArgsKey, err)
 				}
 			}
+			f.writeLabel(b, f.label(key), keySize, keyColour)
+			writeSQL(b, reflowSQL(q, keywordColour), args, indent, dimColour)
+			continue
 		}
 
-		if err == nil && f.isTerminal {
-			if pretty, pErr := f.jsonFmt.Format(data); pErr == nil {
-				data = pretty
+		if key == f.StackKey && terminal && !oneLine {
+			if frames, ok := f.stackFrames(value); ok {
+				f.writeLabel(b, f.label(key), keySize, keyColour)
+				f.writeStack(b, frames, indent, stackColour, dimColour)
+				continue
 			}
 		}
 
+		var detail []string
+		if v, ok := value.(error); ok && key == f.ErrorKey {
+			if f.ShowErrorStack && terminal && !oneLine {
+				detail = f.errorDetail(v)
+			}
+			value = f.errorText(v)
+		}
+
+		data, err := renderValue(key, value, keyRules)
 		if err != nil {
-			data = []byte(fmt.Sprintf("%#v", data))
+			return nil, err
 		}
 
-		if f.isTerminal {
-			l := keySize - len(key)
-			b.Write(bNewline)
-			fmt.Fprintf(b, "  %s: ", dataColour(key))
-			b.Write(bytes.Repeat(bSpace, l))
-			if f.CompactFull || (f.CompactSimple && len(data) < 100) {
-				b.Write(reCompact.ReplaceAll(data, bSpace))
+		label := f.label(key)
+		if inlineData {
+			label = quoteKey(label)
+		}
+		if oneLine {
+			b.WriteByte(' ')
+			b.WriteString(keyColour(label))
+			b.WriteByte('=')
+			writeCompact(b, data)
+		} else if terminal {
+			f.writeLabel(b, label, keySize, keyColour)
+			if f.compact(key, data, fits) {
+				if width > 0 {
+					var line bytes.Buffer
+					writeCompact(&line, data)
+					writeIndented(b, []byte(wrap(line.String(), width-indent)), indent)
+				} else {
+					writeCompact(b, data)
+				}
 			} else {
-				b.Write(bytes.Replace(data, bNewline, padding, -1))
+				writeIndented(b, data, indent)
+			}
+			for _, line := range detail {
+				b.Write(bNewline)
+				writeSpaces(b, indent)
+				b.WriteString(dimColour(line))
 			}
 		} else {
-			fmt.Fprintf(b, "  %s=", key)
+			b.WriteString("  ")
+			b.WriteString(label)
+			b.WriteByte('=')
 			b.Write(data)
 		}
 	}
+	if tag != "" && inlineData {
+		fmt.Fprintf(b, " %s", tag)
+	}
+	if trailer != "" && inlineData {
+		writeTrailer(b, dimColour(trailer), trailerWidth)
+	}
 	b.Write(bNewline)
 
 	if !cuddleMessage {
-		fmt.Fprintf(b, "  %s\n", entry.Message)
+		column := visibleWidth([]byte(f.MessagePrefix))
+		text := message
+		if width > 0 {
+			text = wrap(text, width-column-visibleWidth([]byte(f.MessageGutter)))
+		}
+		text = colourLines(text, messageColour)
+		if linking {
+			text = linkURLs(text)
+		}
+		text = string(applyHighlights([]byte(text), highlights))
+		fmt.Fprintf(b, "%s%s\n", f.MessagePrefix, indentLines(text, column, f.MessageGutter))
 		if f.ParagraphAll || f.ParagraphBlock {
 			b.Write(bNewline)
 		}
 	} else if f.ParagraphAll {
 		b.Write(bNewline)
 	}
+	if banner != "" {
+		fmt.Fprintln(b, banner)
+	}
+
+	return f.finish(f.journal(entry.Level, b.Bytes()), pooled), nil
+}
+
+// withData returns a copy of the entry with the fields merged into its data.
+func withData(entry *logrus.Entry, fields logrus.Fields) *logrus.Entry {
+	data := make(logrus.Fields, len(entry.Data)+len(fields))
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	for k, v := range fields {
+		data[k] = v
+	}
+	e := *entry
+	e.Data = data
+	return &e
+}
+
+// finish applies the final transformations to the rendered entry, copying it out of a pooled buffer.
+func (f *Formatter) finish(out []byte, pooled bool) []byte {
+	if f.FileColor {
+		out = onlySGR(out)
+	}
+	if f.PostProcess != nil {
+		out = f.PostProcess(out)
+	}
+	if pooled {
+		out = append([]byte(nil), out...)
+	}
+	return out
+}
 
-	return b.Bytes(), nil
+// inlineable reports whether the field can be considered for InlineFields, as queries, stack traces and errors with
+// details always need the block.
+func (f *Formatter) inlineable(key string, value interface{}) bool {
+	if _, ok := value.(string); ok && f.isSQLKey(key) {
+		return false
+	}
+	if key == f.StackKey {
+		if _, ok := f.stackFrames(value); ok {
+			return false
+		}
+	}
+	if v, ok := value.(error); ok && key == f.ErrorKey && f.ShowErrorStack {
+		return len(f.errorDetail(v)) == 0
+	}
+	return true
 }
@@ -1,11 +1,19 @@
 module github.com/norganna/formatrus
 
+go 1.17
+
 require (
 	github.com/fatih/color v1.7.0
 	github.com/hokaccha/go-prettyjson v0.0.0-20180528130907-d229c224a219
 	github.com/mgutz/ansi v0.0.0-20170206155736-9520e82c474b
 	github.com/norganna/depict v1.0.8
-	github.com/sirupsen/logrus v1.0.5
-	golang.org/x/crypto v0.0.0-20180608092829-8ac0e0d97ce4
-	golang.org/x/sys v0.0.0-20180612142214-a9e25c09b96b
+	github.com/sirupsen/logrus v1.4.2
+	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
+)
+
+require (
+	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
+	github.com/mattn/go-colorable v0.1.8 // indirect
+	github.com/mattn/go-isatty v0.0.12 // indirect
 )
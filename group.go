@@ -0,0 +1,246 @@
+package formatrus
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Rename displays the data keys under new names, such as `request_id` as `req` (chainable call).
+// Renaming happens before anything else looks at the keys, so options such as `Order`, `Hide` and `GroupDottedKeys`
+// refer to the new names, though `Redact` still applies to the original ones. A key isn't renamed if another field
+// already has the new name, or if another of the entry's keys would be renamed to it too.
+func (f *Formatter) Rename(names map[string]string) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	renames := make(map[string]string, len(f.renames)+len(names))
+	for from, to := range f.renames {
+		renames[from] = to
+	}
+	for from, to := range names {
+		renames[from] = to
+	}
+	f.renames = renames
+	return f
+}
+
+// renamed applies the renames to the data, redacting the values of renamed keys that match the original names, and
+// reporting whether anything was renamed.
+func (f *Formatter) renamed(data logrus.Fields) (logrus.Fields, bool) {
+	r := f.root()
+	r.mu.RLock()
+	renames := r.renames
+	redactor := r.redaction
+	r.mu.RUnlock()
+
+	if len(renames) == 0 {
+		return data, false
+	}
+
+	// Count the keys renamed to each name, so that conflicting renames can be left out.
+	targets := map[string]int{}
+	for key := range data {
+		if to, ok := renames[key]; ok {
+			targets[to]++
+		}
+	}
+
+	res := make(logrus.Fields, len(data))
+	var moved map[string]string
+	for key, value := range data {
+		to, ok := renames[key]
+		if _, taken := data[to]; !ok || taken || targets[to] > 1 || key == OrderKey {
+			res[key] = value
+			continue
+		}
+		if redactor.matches(key) {
+			value = Redacted
+		}
+		res[to] = value
+		if moved == nil {
+			moved = map[string]string{}
+		}
+		moved[key] = to
+	}
+	if moved == nil {
+		return data, false
+	}
+
+	if order, ok := data[OrderKey].([]string); ok {
+		next := make([]string, 0, len(order))
+		for _, key := range order {
+			if to, ok := moved[key]; ok {
+				key = to
+			}
+			next = append(next, key)
+		}
+		res[OrderKey] = next
+	}
+	return res, true
+}
+
+// groupDotted nests dotted data keys (such as `http.method`) into maps under their first segment. Keys whose group
+// would collide with an existing field that isn't a group are left as they are. Values are redacted by their dotted
+// key before they're nested, as the key is lost once they are.
+func groupDotted(data logrus.Fields, redactor *redaction) logrus.Fields {
+	grouped := make(logrus.Fields, len(data))
+	var dotted []string
+	for key, value := range data {
+		if key != OrderKey && strings.Contains(strings.Trim(key, "."), ".") {
+			dotted = append(dotted, key)
+			continue
+		}
+		grouped[key] = value
+	}
+	if len(dotted) == 0 {
+		return data
+	}
+
+	// Sorted so that the result doesn't depend on map order when keys conflict.
+	sort.Strings(dotted)
+	renamed := map[string]string{}
+	for _, key := range dotted {
+		value := data[key]
+		if redactor.matches(key) {
+			value = Redacted
+		}
+		if insert(grouped, strings.Split(key, "."), value) {
+			renamed[key] = key[:strings.IndexByte(key, '.')]
+		} else {
+			grouped[key] = data[key]
+		}
+	}
+
+	if order, ok := data[OrderKey].([]string); ok {
+		next := make([]string, 0, len(order))
+		for _, key := range order {
+			if group, ok := renamed[key]; ok {
+				key = group
+			}
+			next = append(next, key)
+		}
+		grouped[OrderKey] = appendOrder(nil, next...)
+	}
+	return grouped
+}
+
+// insert places the value at the path within nested maps, reporting false if a non-map value is in the way.
+func insert(m map[string]interface{}, path []string, value interface{}) bool {
+	key := path[0]
+	if len(path) == 1 {
+		if _, exists := m[key]; exists {
+			return false
+		}
+		m[key] = value
+		return true
+	}
+
+	var child map[string]interface{}
+	switch v := m[key].(type) {
+	case nil:
+		if _, exists := m[key]; exists {
+			return false
+		}
+		child = map[string]interface{}{}
+		m[key] = child
+	case map[string]interface{}:
+		// Copied, as the map may belong to the entry.
+		child = make(map[string]interface{}, len(v)+1)
+		for k, c := range v {
+			child[k] = c
+		}
+		if !insert(child, path[1:], value) {
+			return false
+		}
+		m[key] = child
+		return true
+	default:
+		return false
+	}
+	return insert(child, path[1:], value)
+}
+
+// flattenNested replaces data values that render as json objects (such as maps and structures) with a field for each
+// of their leaf values, under dotted keys such as `http.method`. Redacted keys, and leaves whose key is already taken,
+// are left as they are.
+func (f *Formatter) flattenNested(data logrus.Fields) logrus.Fields {
+	redactor := f.redactor()
+	var res logrus.Fields
+	for key, value := range data {
+		if key == OrderKey || redactor.matches(key) || !f.nested(value) {
+			continue
+		}
+		rendered, err := f.marshal(value)
+		if err != nil || len(rendered) == 0 || rendered[0] != '{' {
+			continue
+		}
+		d := json.NewDecoder(bytes.NewReader(rendered))
+		d.UseNumber()
+		var m map[string]interface{}
+		if d.Decode(&m) != nil || len(m) == 0 {
+			continue
+		}
+		if res == nil {
+			res = make(logrus.Fields, len(data))
+			for k, v := range data {
+				res[k] = v
+			}
+		}
+		delete(res, key)
+		flatten(res, key, m)
+	}
+	if res == nil {
+		return data
+	}
+	return res
+}
+
+// nested reports whether the value is a map or structure (which may render as a json object).
+func (f *Formatter) nested(value interface{}) bool {
+	v := reflect.Indirect(reflect.ValueOf(f.resolved(value)))
+	return v.Kind() == reflect.Map || v.Kind() == reflect.Struct
+}
+
+// flatten adds the leaves of the decoded json object to the data under the prefix.
+func flatten(data logrus.Fields, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		key := prefix + "." + k
+		switch t := v.(type) {
+		case map[string]interface{}:
+			if len(t) > 0 {
+				flatten(data, key, t)
+				continue
+			}
+		}
+		if _, exists := data[key]; !exists {
+			data[key] = numbers(v)
+		}
+	}
+}
+
+// numbers converts the json numbers in a decoded value back into integers or floats.
+func numbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+		if n, err := t.Float64(); err == nil {
+			return n
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = numbers(e)
+		}
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = numbers(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,92 @@
+// Package grpcformatrus renders gRPC statuses and protobuf messages in formatrus output.
+// It's a separate module so that formatrus itself doesn't depend on gRPC. Importing it registers the renderers with
+// `formatrus.DefaultFormatter`, and WithGRPC registers them with other formatters.
+package grpcformatrus
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/norganna/formatrus"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	statusType  = reflect.TypeOf((*status.Status)(nil))
+	statusError = reflect.TypeOf((*interface{ GRPCStatus() *status.Status })(nil)).Elem()
+	messageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+func init() {
+	WithGRPC(formatrus.DefaultFormatter)
+}
+
+// WithGRPC registers renderers for `*status.Status` values, errors carrying a gRPC status, and protobuf messages
+// (chainable call).
+func WithGRPC(f *formatrus.Formatter) *formatrus.Formatter {
+	return f.
+		RegisterRenderer(statusType, RenderStatus).
+		RegisterRenderer(statusError, RenderStatus).
+		RegisterRenderer(messageType, RenderMessage)
+}
+
+// RenderStatus renders a `*status.Status` (or an error carrying one) as its code, message and any details.
+func RenderStatus(v interface{}) ([]byte, error) {
+	var s *status.Status
+	switch t := v.(type) {
+	case *status.Status:
+		s = t
+	case interface{ GRPCStatus() *status.Status }:
+		s = t.GRPCStatus()
+	}
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	var b bytes.Buffer
+	code, _ := json.Marshal(s.Code().String())
+	message, _ := json.Marshal(s.Message())
+	b.WriteString(`{"code":`)
+	b.Write(code)
+	b.WriteString(`,"message":`)
+	b.Write(message)
+
+	if details := s.Proto().GetDetails(); len(details) > 0 {
+		b.WriteString(`,"details":[`)
+		for i, detail := range details {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			data, err := RenderMessage(detail)
+			if err != nil {
+				return nil, err
+			}
+			b.Write(data)
+		}
+		b.WriteByte(']')
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// RenderMessage renders a protobuf message using its canonical json mapping.
+func RenderMessage(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok || m == nil {
+		return []byte("null"), nil
+	}
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// Protojson deliberately varies its whitespace, so normalise it.
+	var b bytes.Buffer
+	if err := json.Compact(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
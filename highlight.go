@@ -0,0 +1,86 @@
+package formatrus
+
+import (
+	"bytes"
+	"regexp"
+)
+
+type highlight struct {
+	re     *regexp.Regexp
+	style  string
+	colour func(string) string
+}
+
+// Highlight colours the parts of messages and data values that match the pattern with the ansi style, such as
+// "red+b" for `\b5\d\d\b` or "yellow" for `(?i)timeout` (chainable call). Earlier patterns take precedence where
+// matches overlap. Highlighting only applies when the output is coloured.
+func (f *Formatter) Highlight(re *regexp.Regexp, style string) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	highlights := make([]highlight, len(f.highlights), len(f.highlights)+1)
+	copy(highlights, f.highlights)
+	f.highlights = append(highlights, highlight{re: re, style: style})
+	return f
+}
+
+// highlighter returns the current highlight rules, with their colours for the formatter's options.
+func (f *Formatter) highlighter() []highlight {
+	r := f.root()
+	r.mu.RLock()
+	rules := r.highlights
+	r.mu.RUnlock()
+
+	highlights := make([]highlight, len(rules))
+	for i, h := range rules {
+		highlights[i] = highlight{re: h.re, style: h.style, colour: f.colourFunc(h.style)}
+	}
+	return highlights
+}
+
+// applyHighlights colours the matches in the visible text, skipping over any escape sequences and restoring the
+// colour that was in effect after each highlight.
+func applyHighlights(text []byte, highlights []highlight) []byte {
+	if len(highlights) == 0 {
+		return text
+	}
+
+	var out bytes.Buffer
+	last := ""
+	pos := 0
+	for _, loc := range reEscape.FindAllIndex(text, -1) {
+		highlightSegment(&out, text[pos:loc[0]], highlights, last)
+		seq := text[loc[0]:loc[1]]
+		out.Write(seq)
+		if reSGR.Match(seq) {
+			last = string(seq)
+		}
+		pos = loc[1]
+	}
+	highlightSegment(&out, text[pos:], highlights, last)
+	return out.Bytes()
+}
+
+// highlightSegment writes a segment of text without escape sequences, colouring the matches.
+func highlightSegment(out *bytes.Buffer, text []byte, highlights []highlight, restore string) {
+	for len(text) > 0 {
+		start, end, best := -1, -1, -1
+		for i, h := range highlights {
+			loc := h.re.FindIndex(text)
+			if loc == nil || loc[0] == loc[1] {
+				continue
+			}
+			if start < 0 || loc[0] < start {
+				start, end, best = loc[0], loc[1], i
+			}
+		}
+		if best < 0 {
+			out.Write(text)
+			return
+		}
+		out.Write(text[:start])
+		out.WriteString(highlights[best].colour(string(text[start:end])))
+		out.WriteString(restore)
+		text = text[end:]
+	}
+}
@@ -0,0 +1,147 @@
+package formatrus
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultHTTPHeaders are the headers shown for `*http.Request` and `*http.Response` values when HTTPHeaders isn't set.
+var DefaultHTTPHeaders = []string{"Content-Type", "User-Agent", "Location", "Authorization", "Cookie", "Set-Cookie"}
+
+// SensitiveHTTPHeaders are the headers whose values are always shown as `Redacted`.
+var SensitiveHTTPHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// jsonObject builds a json object with its keys in the order they're added.
+type jsonObject struct {
+	bytes.Buffer
+}
+
+// add appends the key and value, skipping empty values.
+func (o *jsonObject) add(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return
+		}
+	case int64:
+		if v <= 0 {
+			return
+		}
+	case json.RawMessage:
+		if v == nil {
+			return
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if o.Len() == 0 {
+		o.WriteByte('{')
+	} else {
+		o.WriteByte(',')
+	}
+	k, _ := json.Marshal(key)
+	o.Write(k)
+	o.WriteByte(':')
+	o.Write(data)
+}
+
+// bytes returns the finished object.
+func (o *jsonObject) bytes() []byte {
+	if o.Len() == 0 {
+		return []byte("{}")
+	}
+	o.WriteByte('}')
+	return o.Buffer.Bytes()
+}
+
+// niceHTTP renders `*http.Request` and `*http.Response` values as a summary, when NiceHTTPTypes is set.
+func (f *Formatter) niceHTTP(v interface{}) ([]byte, bool) {
+	if !f.NiceHTTPTypes {
+		return nil, false
+	}
+	var o jsonObject
+	switch t := v.(type) {
+	case *http.Request:
+		if t == nil {
+			return nil, false
+		}
+		f.addRequest(&o, t)
+		o.add("length", t.ContentLength)
+		o.add("headers", f.httpHeaders(t.Header))
+	case *http.Response:
+		if t == nil {
+			return nil, false
+		}
+		o.add("status", t.Status)
+		if t.Request != nil {
+			f.addRequest(&o, t.Request)
+		}
+		o.add("length", t.ContentLength)
+		o.add("headers", f.httpHeaders(t.Header))
+	default:
+		return nil, false
+	}
+	return o.bytes(), true
+}
+
+// addRequest adds the request's method and url.
+func (f *Formatter) addRequest(o *jsonObject, r *http.Request) {
+	o.add("method", r.Method)
+	if r.URL != nil {
+		o.add("url", redactedURL(r.URL))
+	}
+}
+
+// redactedURL returns the url with any password and query values replaced by "xxxxx", as they often hold credentials.
+func redactedURL(u *url.URL) string {
+	c := *u
+	if query := c.Query(); len(query) > 0 {
+		for _, values := range query {
+			for i := range values {
+				values[i] = "xxxxx"
+			}
+		}
+		c.RawQuery = query.Encode()
+	}
+	return c.Redacted()
+}
+
+// httpHeaders returns the selected headers (in order) as a json object, or nil if there aren't any.
+func (f *Formatter) httpHeaders(header http.Header) json.RawMessage {
+	names := f.HTTPHeaders
+	if names == nil {
+		names = DefaultHTTPHeaders
+	}
+
+	var o jsonObject
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		if sensitiveHeader(name) {
+			value = Redacted
+		}
+		o.add(http.CanonicalHeaderKey(name), value)
+	}
+	if o.Len() == 0 {
+		return nil
+	}
+	return o.bytes()
+}
+
+// sensitiveHeader reports whether the header's value shouldn't be logged.
+func sensitiveHeader(name string) bool {
+	for _, s := range SensitiveHTTPHeaders {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	return false
+}
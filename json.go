@@ -0,0 +1,117 @@
+package formatrus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Keys of the header values in `NonTerminalJSON` output. Data fields with the same names are prefixed with "fields.".
+const (
+	JSONTimeKey  = "time"
+	JSONLevelKey = "level"
+	JSONMsgKey   = "msg"
+	JSONFuncKey  = "func"
+	JSONFileKey  = "file"
+)
+
+// formatJSON renders the entry as a single line json object.
+func (f *Formatter) formatJSON(entry *logrus.Entry, b *bytes.Buffer) error {
+	when := entry.Time
+	if f.FixZeroTime && when.IsZero() {
+		when = time.Now()
+	}
+	if f.TimestampUTC {
+		when = when.UTC()
+	}
+
+	type field struct {
+		key  string
+		data json.RawMessage
+	}
+	header := []field{
+		{key: JSONTimeKey},
+		{key: JSONLevelKey},
+		{key: JSONMsgKey},
+	}
+	header[0].data, _ = json.Marshal(when.Format(time.RFC3339Nano))
+	header[1].data, _ = json.Marshal(entry.Level.String())
+	header[2].data, _ = json.Marshal(entry.Message)
+	if f.Deterministic {
+		header = header[1:]
+	}
+	if entry.Caller != nil {
+		fn, _ := json.Marshal(entry.Caller.Function)
+		file, _ := json.Marshal(strings.TrimPrefix(entry.Caller.File, f.CallerTrimPrefix) + ":" +
+			strconv.Itoa(entry.Caller.Line))
+		header = append(header, field{JSONFuncKey, fn}, field{JSONFileKey, file})
+	}
+	traceKey, spanKey := f.traceKeys()
+	traceID, spanID := f.traceIDs(entry)
+	if traceID != "" {
+		id, _ := json.Marshal(traceID)
+		header = append(header, field{traceKey, id})
+	}
+	if spanID != "" {
+		id, _ := json.Marshal(spanID)
+		header = append(header, field{spanKey, id})
+	}
+
+	reserved := map[string]bool{}
+	for _, h := range header {
+		reserved[h.key] = true
+	}
+
+	redactor := f.redactor()
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		if key == OrderKey {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := header
+	for _, key := range keys {
+		value := entry.Data[key]
+		if redactor.matches(key) {
+			value = Redacted
+		}
+
+		data, err := f.marshal(value)
+		if err != nil {
+			if f.Strict {
+				return fmt.Errorf("formatrus: unable to render field %q: %v", key, err)
+			}
+			data, _ = json.Marshal(f.safeText(func() string {
+				return stringify(value)
+			}))
+		}
+
+		name := key
+		if reserved[name] {
+			name = "fields." + name
+		}
+		fields = append(fields, field{name, data})
+	}
+
+	b.WriteByte('{')
+	for i, fd := range fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(fd.key)
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(fd.data)
+	}
+	b.WriteString("}\n")
+	return nil
+}
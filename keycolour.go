@@ -0,0 +1,69 @@
+package formatrus
+
+// KeyColor shows the data key in the ansi style (such as "yellow+b") in place of the theme's Key style, so that
+// important keys stand out (chainable call).
+func (f *Formatter) KeyColor(key, style string) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keyStyles := make(map[string]string, len(f.keyStyles)+1)
+	for k, s := range f.keyStyles {
+		keyStyles[k] = s
+	}
+	keyStyles[key] = style
+	f.keyStyles = keyStyles
+	return f
+}
+
+// keyColours returns the colours of the current key styles, for the formatter's options.
+func (f *Formatter) keyColours() map[string]func(string) string {
+	r := f.root()
+	r.mu.RLock()
+	keyStyles := r.keyStyles
+	r.mu.RUnlock()
+
+	if len(keyStyles) == 0 {
+		return nil
+	}
+	colours := make(map[string]func(string) string, len(keyStyles))
+	for key, style := range keyStyles {
+		colours[key] = f.colourFunc(style)
+	}
+	return colours
+}
+
+// ColorRule returns the ansi style for a data value, or "" to leave it in the usual colours.
+type ColorRule func(v interface{}) string
+
+// ColorIf styles the key's values according to the rule, such as red for a `status` of 500 or more (chainable call).
+// Where a key has several rules, the first to return a style wins. Rules aren't applied to redacted values.
+func (f *Formatter) ColorIf(key string, rule ColorRule) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rules := make(map[string][]ColorRule, len(f.rules)+1)
+	for k, r := range f.rules {
+		rules[k] = r
+	}
+	rules[key] = append(rules[key][:len(rules[key]):len(rules[key])], rule)
+	f.rules = rules
+	return f
+}
+
+// valueRules returns the current value colour rules.
+func (f *Formatter) valueRules() map[string][]ColorRule {
+	r := f.root()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rules
+}
+
+// valueStyle returns the style of the first of the rules to give one for the value.
+func valueStyle(rules []ColorRule, v interface{}) string {
+	for _, rule := range rules {
+		if style := rule(v); style != "" {
+			return style
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package formatrus
+
+import (
+	"strings"
+)
+
+// KeySort denotes how data keys are sorted (after any priority ordering).
+type KeySort int
+
+const (
+	// SortLexical sorts keys by their bytes.
+	SortLexical KeySort = iota
+	// SortCaseInsensitive sorts keys ignoring case.
+	SortCaseInsensitive
+	// SortNatural sorts keys ignoring case and comparing runs of digits numerically ("file2" before "file10").
+	SortNatural
+)
+
+// keyLess returns the comparison used to sort keys, or nil for the default lexical sort.
+func (f *Formatter) keyLess() func(a, b string) bool {
+	if f.KeySortFunc != nil {
+		return f.KeySortFunc
+	}
+	switch f.KeySort {
+	case SortCaseInsensitive:
+		return func(a, b string) bool {
+			la, lb := strings.ToLower(a), strings.ToLower(b)
+			if la == lb {
+				return a < b
+			}
+			return la < lb
+		}
+	case SortNatural:
+		return naturalLess
+	}
+	return nil
+}
+
+// naturalLess compares strings case-insensitively, treating runs of digits as numbers.
+func naturalLess(a, b string) bool {
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	i, j := 0, 0
+	for i < len(la) && j < len(lb) {
+		ca, cb := la[i], lb[j]
+		if isDigit(ca) && isDigit(cb) {
+			si, sj := i, j
+			for i < len(la) && isDigit(la[i]) {
+				i++
+			}
+			for j < len(lb) && isDigit(lb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(la[si:i], "0")
+			nb := strings.TrimLeft(lb[sj:j], "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	if len(la)-i != len(lb)-j {
+		return len(la)-i < len(lb)-j
+	}
+	return a < b
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,55 @@
+package formatrus
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// LazyValue is a data value that's only computed when an entry is formatted.
+type LazyValue struct {
+	once    sync.Once
+	fn      func() interface{}
+	value   interface{}
+	failure interface{}
+}
+
+// Lazy wraps an expensive to compute data value (such as a large serialisation) so that it's only computed when the
+// field is rendered, and not at all if the entry isn't logged or the field is hidden, filtered or redacted. It's
+// computed at most once, however many formatters (or tees) render it.
+func Lazy(fn func() interface{}) *LazyValue {
+	return &LazyValue{fn: fn}
+}
+
+// Value computes the value, if it hasn't been already. If computing it panicked, every call panics in the same way.
+func (l *LazyValue) Value() interface{} {
+	l.once.Do(func() {
+		defer func() {
+			l.failure = recover()
+		}()
+		if l.fn != nil {
+			l.value = l.fn()
+		}
+	})
+	if l.failure != nil {
+		panic(l.failure)
+	}
+	return l.value
+}
+
+// String renders the value as text, for formatters that use `fmt`.
+func (l *LazyValue) String() string {
+	return stringify(l.Value())
+}
+
+// MarshalJSON renders the value as json, for formatters that use `encoding/json`.
+func (l *LazyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Value())
+}
+
+// resolve computes the value if it's lazy.
+func resolve(v interface{}) interface{} {
+	if l, ok := v.(*LazyValue); ok {
+		return l.Value()
+	}
+	return v
+}
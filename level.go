@@ -0,0 +1,143 @@
+package formatrus
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelCase sets the case of the level text.
+type LevelCase int
+
+const (
+	// LevelCaseDefault uses the deprecated LevelUpper and LevelLower options.
+	LevelCaseDefault LevelCase = iota
+	// LevelCaseUpper renders the level as "INF".
+	LevelCaseUpper
+	// LevelCaseLower renders the level as "inf".
+	LevelCaseLower
+	// LevelCaseTitle renders the level as "Inf".
+	LevelCaseTitle
+	// LevelCaseAsIs leaves the level text unchanged.
+	LevelCaseAsIs
+)
+
+// apply changes the case of the text.
+func (c LevelCase) apply(text string) string {
+	switch c {
+	case LevelCaseUpper:
+		return strings.ToUpper(text)
+	case LevelCaseLower:
+		return strings.ToLower(text)
+	case LevelCaseTitle:
+		if text == "" {
+			return text
+		}
+		return strings.ToUpper(text[:1]) + strings.ToLower(text[1:])
+	}
+	return text
+}
+
+// levelCase resolves the LevelCase, taking the deprecated options into account.
+func (f *Formatter) levelCase() LevelCase {
+	switch {
+	case f.LevelCase != LevelCaseDefault:
+		return f.LevelCase
+	case f.LevelLower:
+		return LevelCaseLower
+	case f.LevelUpper:
+		return LevelCaseUpper
+	}
+	return LevelCaseAsIs
+}
+
+// IconMode sets whether a glyph is shown for the level.
+type IconMode int
+
+const (
+	// IconsOff shows just the level text.
+	IconsOff IconMode = iota
+	// IconsAlongside shows the glyph before the level text.
+	IconsAlongside
+	// IconsOnly shows the glyph instead of the level text.
+	IconsOnly
+)
+
+// DefaultLevelIcons are the glyphs shown for each level, unless replaced by `LevelIcon`.
+var DefaultLevelIcons = map[logrus.Level]string{
+	logrus.TraceLevel: "∙",
+	logrus.DebugLevel: "◆",
+	logrus.InfoLevel:  "✔",
+	logrus.WarnLevel:  "⚠",
+	logrus.ErrorLevel: "✖",
+	logrus.FatalLevel: "☠",
+	logrus.PanicLevel: "‼",
+}
+
+// levelIcon returns the glyph for the level.
+func (f *Formatter) levelIcon(level logrus.Level) string {
+	if icon, ok := f.LevelIcon[level]; ok {
+		return icon
+	}
+	return DefaultLevelIcons[level]
+}
+
+// severity returns the rank of the level, where lower numbers are more severe, and whether LevelSeverityOrder (if
+// set) ranks it.
+func (f *Formatter) severity(level logrus.Level) (int, bool) {
+	if len(f.LevelSeverityOrder) == 0 {
+		return int(level), true
+	}
+	for i, l := range f.LevelSeverityOrder {
+		if l == level {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// atLeast reports whether level is at least as severe as min. Levels are compared by logrus's order if either isn't
+// ranked by LevelSeverityOrder.
+func (f *Formatter) atLeast(level, min logrus.Level) bool {
+	l, ok := f.severity(level)
+	m, minOK := f.severity(min)
+	if !ok || !minOK {
+		return level <= min
+	}
+	return l <= m
+}
+
+// simplified reports whether an entry at the level is rendered on a single line because of SimplifyBelow.
+func (f *Formatter) simplified(level logrus.Level) bool {
+	return f.SimplifyBelow != nil && !f.atLeast(level, *f.SimplifyBelow)
+}
+
+// colourLevel reports whether entries at the level may be coloured.
+func (f *Formatter) colourLevel(level logrus.Level) bool {
+	if f.ColorLevels == nil {
+		return true
+	}
+	for _, l := range f.ColorLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// customLevelText returns the LevelText for the level, padded to the width of the longest one so that the header
+// stays aligned.
+func (f *Formatter) customLevelText(level logrus.Level) (string, bool) {
+	text, ok := f.LevelText[level]
+	if !ok {
+		return "", false
+	}
+	width := 0
+	for _, t := range f.LevelText {
+		if n := utf8.RuneCountInString(t); n > width {
+			width = n
+		}
+	}
+	return text + strings.Repeat(" ", width-utf8.RuneCountInString(text)), true
+}
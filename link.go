@@ -0,0 +1,82 @@
+package formatrus
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var reURL = regexp.MustCompile(`\bhttps?://[^\s"'<>\x1b\\]*(\\u00[0-9a-f]{2}[^\s"'<>\x1b\\]*)*`)
+var jsonUnescaper = strings.NewReplacer(`\u0026`, "&", `\u003c`, "<", `\u003e`, ">")
+
+// hyperlinks reports whether the terminal is known to support OSC 8 hyperlinks.
+// `FORCE_HYPERLINK` can be set to 1 (or 0) to override the detection.
+func hyperlinks() bool {
+	if v := os.Getenv("FORCE_HYPERLINK"); v != "" {
+		return v != "0"
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "Hyper":
+		return true
+	}
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty" {
+		return true
+	}
+	// VTE based terminals (such as gnome-terminal) support hyperlinks from 0.50.
+	if v, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && v >= 5000 {
+		return true
+	}
+	return false
+}
+
+// link wraps the text in an OSC 8 hyperlink to the target.
+func link(target, text string) string {
+	return "\x1b]8;;" + escapeTarget(target) + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// escapeTarget percent-encodes the bytes of a hyperlink target that OSC 8 doesn't allow (those outside 33 to 126),
+// which include the control characters that could otherwise end the sequence early.
+func escapeTarget(target string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(target); i++ {
+		if c := target[i]; c > ' ' && c < 0x7f {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+		}
+	}
+	return b.String()
+}
+
+// linkTarget returns the target for a `LinkKeys` value, turning file paths into file URLs.
+func linkTarget(value string) string {
+	if strings.Contains(value, "://") {
+		return value
+	}
+	if abs, err := filepath.Abs(value); err == nil {
+		return "file://" + filepath.ToSlash(abs)
+	}
+	return value
+}
+
+// linkURLs turns the URLs in the (possibly json encoded) text into hyperlinks.
+func linkURLs(text string) string {
+	return reURL.ReplaceAllStringFunc(text, func(url string) string {
+		return link(jsonUnescaper.Replace(url), url)
+	})
+}
+
+// isLinkKey reports whether the key's values should always be hyperlinked.
+func (f *Formatter) isLinkKey(key string) bool {
+	for _, k := range f.LinkKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package formatrus
+
+import "testing"
+
+func TestLinkURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "plain",
+			text: "see https://x.io/a for more",
+			want: "see \x1b]8;;https://x.io/a\x1b\\https://x.io/a\x1b]8;;\x1b\\ for more",
+		},
+		{
+			name: "json escaped query",
+			text: `"https://x.io/a?b=1\u0026c=2"`,
+			want: "\"\x1b]8;;https://x.io/a?b=1&c=2\x1b\\https://x.io/a?b=1\\u0026c=2\x1b]8;;\x1b\\\"",
+		},
+		{
+			name: "json escaped brackets",
+			text: `"https://x.io/\u003cid\u003e"`,
+			want: "\"\x1b]8;;https://x.io/<id>\x1b\\https://x.io/\\u003cid\\u003e\x1b]8;;\x1b\\\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := linkURLs(tt.text); got != tt.want {
+				t.Errorf("linkURLs(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkEscapesTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{
+			name:   "plain",
+			target: "https://x.io/a?b=1&c=2",
+			want:   "https://x.io/a?b=1&c=2",
+		},
+		{
+			name:   "terminators",
+			target: "https://x.io/\x1b]8;;https://evil.io\x07\x1b\\",
+			want:   "https://x.io/%1B]8;;https://evil.io%07%1B\\",
+		},
+		{
+			name:   "c1 controls",
+			target: "https://x.io/\u009b31m\x9d",
+			want:   "https://x.io/%C2%9B31m%9D",
+		},
+		{
+			name:   "spaces and unicode",
+			target: "file:///tmp/a b/é\x7f",
+			want:   "file:///tmp/a%20b/%C3%A9%7F",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := "\x1b]8;;" + tt.want + "\x1b\\text\x1b]8;;\x1b\\"
+			if got := link(tt.target, "text"); got != want {
+				t.Errorf("link(%q) = %q, want %q", tt.target, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package formatrus
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Option configures a formatter as it's created by `New` or `Build`.
+type Option func(f *Formatter) error
+
+// WithLevelLetters sets the number of letters of the level text to show (from 1 to 5).
+func WithLevelLetters(letters int) Option {
+	return func(f *Formatter) error {
+		if letters < 1 || letters > 5 {
+			return fmt.Errorf("formatrus: level letters must be from 1 to 5, not %d", letters)
+		}
+		f.LevelLetters = letters
+		return nil
+	}
+}
+
+// WithLevelCase sets the case of the level text.
+func WithLevelCase(c LevelCase) Option {
+	return func(f *Formatter) error {
+		f.LevelCase = c
+		return nil
+	}
+}
+
+// WithFieldsMinLevel only renders the data fields of entries at least as severe as the level.
+func WithFieldsMinLevel(level logrus.Level) Option {
+	return func(f *Formatter) error {
+		f.FieldsMinLevel = &level
+		return nil
+	}
+}
+
+// WithOrdering gives the keys a priority, as per `Order`.
+func WithOrdering(priority int, keys ...string) Option {
+	return func(f *Formatter) error {
+		f.Order(priority, keys...)
+		return nil
+	}
+}
+
+// WithTheme sets the styles used for each element of the output.
+func WithTheme(theme Theme) Option {
+	return func(f *Formatter) error {
+		f.Theme = theme
+		return nil
+	}
+}
+
+// WithTimestampFormat sets the time layout of the timestamp.
+func WithTimestampFormat(format string) Option {
+	return func(f *Formatter) error {
+		if format == "" {
+			return fmt.Errorf("formatrus: timestamp format can't be empty, use DisableTimestamp instead")
+		}
+		f.TimestampFormat = format
+		return nil
+	}
+}
+
+// WithKeyWidth sets the minimum and maximum widths of the data keys (a max of 0 is unlimited).
+func WithKeyWidth(min, max int) Option {
+	return func(f *Formatter) error {
+		f.KeyMinWidth = min
+		f.KeyMaxWidth = max
+		return nil
+	}
+}
+
+// WithRedact redacts the values of the keys, as per `Redact`.
+func WithRedact(keys ...string) Option {
+	return func(f *Formatter) error {
+		f.Redact(keys...)
+		return nil
+	}
+}
+
+// WithHide hides the keys, as per `Hide`.
+func WithHide(keys ...string) Option {
+	return func(f *Formatter) error {
+		f.Hide(keys...)
+		return nil
+	}
+}
+
+// Build creates a formatter with the defaults from `New`, applies the options, and validates the result.
+func Build(opts ...Option) (*Formatter, error) {
+	f := defaults()
+	for _, opt := range opts {
+		if err := opt(f); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Validate checks the options for values and combinations that can't be used together.
+func (f *Formatter) Validate() error {
+	if f.LevelLetters < 0 || f.LevelLetters > 5 {
+		return fmt.Errorf("formatrus: level letters must be from 1 to 5 (or 0 for the default), not %d", f.LevelLetters)
+	}
+	if f.KeyMaxWidth > 0 && f.KeyMinWidth > f.KeyMaxWidth {
+		return fmt.Errorf("formatrus: key min width (%d) is more than the max width (%d)", f.KeyMinWidth, f.KeyMaxWidth)
+	}
+	if f.KeyMinWidth < 0 || f.KeyMaxWidth < 0 || f.FixedKeyWidth < 0 {
+		return fmt.Errorf("formatrus: key widths can't be negative")
+	}
+	if f.MaxDepth < 0 || f.MaxElements < 0 || f.MaxValueLength < 0 || f.PreviewDepth < 0 {
+		return fmt.Errorf("formatrus: limits can't be negative")
+	}
+	for _, pair := range f.DiffPairs {
+		if pair[0] == pair[1] {
+			return fmt.Errorf("formatrus: diff pair %q can't be compared with itself", pair[0])
+		}
+	}
+	return nil
+}
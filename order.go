@@ -0,0 +1,58 @@
+package formatrus
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// OrderKey is the data key holding a `[]string` of keys in the order they should be rendered.
+// logrus stores fields in a map, so the order they were added in can't be recovered by the formatter; callers that
+// care should record it via `WithField`, `WithFields` or `Ordered`. Setting this key directly is still supported.
+const OrderKey = "_order"
+
+// Field is a single data key and value, as held by `Fields`.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Fields is a list of data fields that keeps the order they were given in.
+type Fields []Field
+
+// WithField adds a field to the entry like `entry.WithField`, additionally recording the key in the entry's
+// `OrderKey` so that fields are rendered in the order they were added.
+func WithField(entry *logrus.Entry, key string, value interface{}) *logrus.Entry {
+	return WithFields(entry, Fields{{key, value}})
+}
+
+// WithFields adds the fields to the entry like `entry.WithFields`, additionally recording their keys in the entry's
+// `OrderKey` so that fields are rendered in the order they were added.
+func WithFields(entry *logrus.Entry, fields Fields) *logrus.Entry {
+	order, _ := entry.Data[OrderKey].([]string)
+	data := make(logrus.Fields, len(fields)+1)
+	keys := make([]string, 0, len(fields))
+	for _, field := range fields {
+		data[field.Key] = field.Value
+		keys = append(keys, field.Key)
+	}
+	data[OrderKey] = appendOrder(order, keys...)
+	return entry.WithFields(data)
+}
+
+// Ordered returns a copy of the entry whose fields are rendered in the given order (ahead of any other fields).
+func Ordered(entry *logrus.Entry, keys ...string) *logrus.Entry {
+	return entry.WithField(OrderKey, appendOrder(nil, keys...))
+}
+
+// appendOrder returns a new order with any keys that aren't already present on the end.
+func appendOrder(order []string, keys ...string) []string {
+	seen := make(map[string]bool, len(order)+len(keys))
+	// Copy rather than append in place, as the slice may be shared with the entry we were derived from.
+	next := make([]string, 0, len(order)+len(keys))
+	for _, k := range append(order[:len(order):len(order)], keys...) {
+		if !seen[k] {
+			seen[k] = true
+			next = append(next, k)
+		}
+	}
+	return next
+}
@@ -0,0 +1,22 @@
+module github.com/norganna/formatrus/otelformatrus
+
+go 1.20
+
+require (
+	github.com/norganna/formatrus v1.1.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/fatih/color v1.7.0 // indirect
+	github.com/hokaccha/go-prettyjson v0.0.0-20180528130907-d229c224a219 // indirect
+	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
+	github.com/mattn/go-colorable v0.1.8 // indirect
+	github.com/mattn/go-isatty v0.0.12 // indirect
+	github.com/mgutz/ansi v0.0.0-20170206155736-9520e82c474b // indirect
+	github.com/norganna/depict v1.0.8 // indirect
+	github.com/sirupsen/logrus v1.4.2 // indirect
+	go.opentelemetry.io/otel v1.24.0 // indirect
+	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68 // indirect
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1 // indirect
+)
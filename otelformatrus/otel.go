@@ -0,0 +1,30 @@
+// Package otelformatrus correlates formatrus output with OpenTelemetry traces.
+// It's a separate module so that formatrus itself doesn't depend on OpenTelemetry.
+package otelformatrus
+
+import (
+	"context"
+
+	"github.com/norganna/formatrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithOTel enables (or disables) rendering the trace and span ids of the span carried by each entry's context
+// (chainable call).
+func WithOTel(f *formatrus.Formatter, enable bool) *formatrus.Formatter {
+	if enable {
+		f.TraceExtractor = SpanIDs
+	} else {
+		f.TraceExtractor = nil
+	}
+	return f
+}
+
+// SpanIDs returns the trace and span ids of the span in the context, if there's a valid one.
+func SpanIDs(ctx context.Context) (traceID, spanID string) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", ""
+	}
+	return sc.TraceID().String(), sc.SpanID().String()
+}
@@ -0,0 +1,72 @@
+package formatrus
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+type override struct {
+	once      sync.Once
+	configure []func(o *Formatter)
+	formatter *Formatter
+}
+
+// ForLevel changes the options used for entries at the given level, such as `CompactFull` for debug entries but
+// full blocks for errors (chainable call). The configure function is given a copy of the formatter's options, taken
+// when the level is first formatted, and may change any of them. Maps and slices are shared with the original, so
+// should be replaced rather than modified.
+// The chainable methods (`Order`, `Redact`, `Hide` etc) always apply to every level, and state such as sequence
+// numbers and terminal detection is shared.
+func (f *Formatter) ForLevel(level logrus.Level, configure func(o *Formatter)) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	overrides := make(map[logrus.Level]*override, len(f.overrides)+1)
+	for l, o := range f.overrides {
+		overrides[l] = o
+	}
+	o := &override{}
+	if prev := f.overrides[level]; prev != nil {
+		o.configure = append(o.configure, prev.configure...)
+	}
+	o.configure = append(o.configure, configure)
+	overrides[level] = o
+	f.overrides = overrides
+
+	return f
+}
+
+// override returns the formatter to use instead for entries at the level, if there is one.
+func (f *Formatter) override(level logrus.Level) *Formatter {
+	f.mu.RLock()
+	o := f.overrides[level]
+	f.mu.RUnlock()
+
+	if o == nil {
+		return nil
+	}
+	o.once.Do(func() {
+		d := f.derive()
+		for _, configure := range o.configure {
+			configure(d)
+		}
+		o.formatter = d
+	})
+	return o.formatter
+}
+
+// derive returns a formatter with a copy of the options, which shares the configuration set by the chainable methods.
+func (f *Formatter) derive() *Formatter {
+	d := &Formatter{base: f.root()}
+	copyOptions(d, f, false)
+	return d
+}
+
+// root returns the formatter that an override was derived from, or the formatter itself.
+func (f *Formatter) root() *Formatter {
+	if f.base != nil {
+		return f.base
+	}
+	return f
+}
@@ -0,0 +1,82 @@
+package formatrus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hokaccha/go-prettyjson"
+)
+
+// panicked is a value whose rendering panicked, which is shown as the placeholder.
+type panicked struct {
+	r interface{}
+}
+
+// panicText is the placeholder shown in place of a value that panicked while being rendered.
+func panicText(r interface{}) string {
+	return fmt.Sprintf("<panic rendering field: %v>", r)
+}
+
+// placeholder returns the placeholder for the panic as json, or as plain text when pretty printing with jsonFmt.
+func placeholder(r interface{}, jsonFmt *prettyjson.Formatter) []byte {
+	if jsonFmt != nil {
+		return []byte(panicText(r))
+	}
+	// Encoded without escaping html characters, so that the placeholder reads as is.
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	_ = e.Encode(panicText(r))
+	return bytes.TrimSuffix(b.Bytes(), bNewline)
+}
+
+// recoverValue, when deferred, replaces a value's rendering with the placeholder if it panicked, unless StrictErrors
+// is set.
+func (f *Formatter) recoverValue(data *[]byte, err *error, jsonFmt *prettyjson.Formatter) {
+	if f.StrictErrors {
+		return
+	}
+	if r := recover(); r != nil {
+		*data, *err = placeholder(r, jsonFmt), nil
+	}
+}
+
+// safeText returns the text from fn, or the placeholder if it panicked (unless StrictErrors is set).
+func (f *Formatter) safeText(fn func() string) (text string) {
+	defer func() {
+		if f.StrictErrors {
+			return
+		}
+		if r := recover(); r != nil {
+			text = panicText(r)
+		}
+	}()
+	return fn()
+}
+
+// errorText returns the error's message, or a panicked value if that panicked (unless StrictErrors is set).
+func (f *Formatter) errorText(err error) (text interface{}) {
+	defer func() {
+		if f.StrictErrors {
+			return
+		}
+		if r := recover(); r != nil {
+			text = panicked{r}
+		}
+	}()
+	return err.Error()
+}
+
+// resolved computes the value if it's lazy, giving a panicked value if that panicked (unless StrictErrors is set).
+func (f *Formatter) resolved(v interface{}) (value interface{}) {
+	defer func() {
+		if f.StrictErrors {
+			return
+		}
+		if r := recover(); r != nil {
+			value = panicked{r}
+		}
+	}()
+	return resolve(v)
+}
@@ -0,0 +1,51 @@
+package formatrus
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooled is the largest capacity kept for reuse, so that the odd huge entry doesn't pin its memory.
+const maxPooled = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &bytes.Buffer{}
+	},
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns the buffer to the pool, once nothing refers to its bytes.
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooled {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+var keysPool = sync.Pool{
+	New: func() interface{} {
+		keys := make([]string, 0, 16)
+		return &keys
+	},
+}
+
+// getKeys returns an empty scratch slice for data keys from the pool.
+func getKeys() *[]string {
+	keys := keysPool.Get().(*[]string)
+	*keys = (*keys)[:0]
+	return keys
+}
+
+// putKeys returns the scratch slice to the pool.
+func putKeys(keys *[]string) {
+	if cap(*keys) > maxPooled/16 {
+		return
+	}
+	keysPool.Put(keys)
+}
@@ -0,0 +1,50 @@
+package formatrus
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type verboseWindow struct {
+	start time.Time
+	count int
+}
+
+// verboseAllowed reports whether the entry may be rendered in full under the VerboseRateLimit, counting it if so.
+// Entries are limited per level and message, within windows of a second.
+func (f *Formatter) verboseAllowed(entry *logrus.Entry) bool {
+	if f.VerboseRateLimit <= 0 {
+		return true
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s", entry.Level, entry.Message)
+	sig := h.Sum64()
+
+	// Overrides share the rate limiting of the formatter they were derived from.
+	r := f.root()
+	r.verboseMu.Lock()
+	defer r.verboseMu.Unlock()
+
+	if r.verbose == nil {
+		r.verbose = map[uint64]*verboseWindow{}
+	}
+	w := r.verbose[sig]
+	if w == nil || entry.Time.Sub(w.start) >= time.Second || entry.Time.Before(w.start) {
+		// Windows that have ended are dropped now and then, so that the map doesn't grow without bounds.
+		if len(r.verbose) >= 1000 {
+			for s, old := range r.verbose {
+				if entry.Time.Sub(old.start) >= time.Second {
+					delete(r.verbose, s)
+				}
+			}
+		}
+		w = &verboseWindow{start: entry.Time}
+		r.verbose[sig] = w
+	}
+	w.count++
+	return w.count <= f.VerboseRateLimit
+}
@@ -0,0 +1,97 @@
+package formatrus
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Redacted is the text that replaces the values of redacted fields.
+const Redacted = "[REDACTED]"
+
+type redaction struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// Redact causes the values of the given keys to be replaced with `Redacted` wherever they appear, including inside
+// nested structures. Keys are matched case-insensitively (chainable call).
+func (f *Formatter) Redact(keys ...string) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r := f.redaction.clone()
+	for _, key := range keys {
+		r.keys[strings.ToLower(key)] = true
+	}
+	f.redaction = r
+	return f
+}
+
+// RedactPattern causes the values of any keys matching the pattern to be replaced with `Redacted` wherever they
+// appear, including inside nested structures (chainable call).
+func (f *Formatter) RedactPattern(re *regexp.Regexp) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r := f.redaction.clone()
+	r.patterns = append(r.patterns, re)
+	f.redaction = r
+	return f
+}
+
+// redactor returns the current redaction rules, or nil if there are none.
+func (f *Formatter) redactor() *redaction {
+	f = f.root()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.redaction
+}
+
+// clone copies the rules so they can be changed without affecting entries being formatted.
+func (r *redaction) clone() *redaction {
+	c := &redaction{
+		keys: map[string]bool{},
+	}
+	if r != nil {
+		for key := range r.keys {
+			c.keys[key] = true
+		}
+		c.patterns = append(c.patterns, r.patterns...)
+	}
+	return c
+}
+
+// matches reports whether the key's value should be redacted.
+func (r *redaction) matches(key string) bool {
+	if r == nil {
+		return false
+	}
+	if r.keys[strings.ToLower(key)] {
+		return true
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact replaces the values of any matching keys within the decoded json value.
+func (r *redaction) redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, c := range t {
+			if r.matches(k) {
+				t[k] = Redacted
+			} else {
+				t[k] = r.redact(c)
+			}
+		}
+	case []interface{}:
+		for i, c := range t {
+			t[i] = r.redact(c)
+		}
+	}
+	return v
+}
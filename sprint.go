@@ -0,0 +1,54 @@
+package formatrus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sprintOutput stands in for a logger's output when formatting standalone entries, and says whether it's a terminal.
+type sprintOutput bool
+
+// Write discards the bytes, as nothing is ever written to a sprintOutput.
+func (sprintOutput) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// Sprint formats the entry with the DefaultFormatter, as if it was being written to a terminal (if terminal is set).
+func Sprint(entry *logrus.Entry, terminal bool) string {
+	return DefaultFormatter.Sprint(entry, terminal)
+}
+
+// Sprintf formats an entry with the level, message and fields using the DefaultFormatter.
+func Sprintf(level logrus.Level, msg string, fields logrus.Fields, terminal bool) string {
+	return DefaultFormatter.Sprintf(level, msg, fields, terminal)
+}
+
+// Sprint formats the entry without needing a logger, as if it was being written to a terminal (if terminal is set).
+// The entry isn't written to any tees, and doesn't affect the formatter's state (e.g. deduplication or sequence
+// numbers). Formatting errors are returned as the text.
+func (f *Formatter) Sprint(entry *logrus.Entry, terminal bool) string {
+	e := *entry
+	e.Logger = &logrus.Logger{Out: sprintOutput(terminal)}
+	e.Buffer = nil
+	if e.Data == nil {
+		e.Data = logrus.Fields{}
+	}
+
+	out, err := f.format(&e, true)
+	if err != nil {
+		return fmt.Sprintf("formatrus: %v", err)
+	}
+	return string(out)
+}
+
+// Sprintf formats an entry with the level, message and fields, timestamped now.
+func (f *Formatter) Sprintf(level logrus.Level, msg string, fields logrus.Fields, terminal bool) string {
+	return f.Sprint(&logrus.Entry{
+		Data:    fields,
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	}, terminal)
+}
@@ -0,0 +1,189 @@
+package formatrus
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// DefaultSQLKeys are the data keys holding sql queries when SQLKeys isn't set.
+var DefaultSQLKeys = []string{"query", "sql"}
+
+// sqlKeywords are the words highlighted in queries.
+var sqlKeywords = map[string]bool{
+	"ALL": true, "ALTER": true, "AND": true, "AS": true, "ASC": true, "BETWEEN": true, "BY": true, "CASE": true,
+	"CONFLICT": true, "CREATE": true, "CROSS": true, "DELETE": true, "DESC": true, "DISTINCT": true, "DO": true,
+	"DROP": true, "ELSE": true, "END": true, "EXISTS": true, "FROM": true, "FULL": true, "GROUP": true,
+	"HAVING": true, "IN": true, "INDEX": true, "INNER": true, "INSERT": true, "INTO": true, "IS": true,
+	"JOIN": true, "LEFT": true, "LIKE": true, "LIMIT": true, "NOT": true, "NOTHING": true, "NULL": true,
+	"OFFSET": true, "ON": true, "OR": true, "ORDER": true, "OUTER": true, "RETURNING": true, "RIGHT": true,
+	"SELECT": true, "SET": true, "TABLE": true, "THEN": true, "UNION": true, "UPDATE": true, "VALUES": true,
+	"WHEN": true, "WHERE": true, "WITH": true,
+}
+
+// sqlClauses are the keywords that start a new line when a query is reflowed.
+var sqlClauses = map[string]bool{
+	"DELETE": true, "FROM": true, "GROUP": true, "HAVING": true, "INSERT": true, "JOIN": true, "LIMIT": true,
+	"OFFSET": true, "ORDER": true, "RETURNING": true, "SELECT": true, "SET": true, "UNION": true, "UPDATE": true,
+	"VALUES": true, "WHERE": true, "WITH": true,
+}
+
+// sqlJoins are the keywords that start a new line when they lead up to a JOIN.
+var sqlJoins = map[string]bool{"CROSS": true, "FULL": true, "INNER": true, "LEFT": true, "OUTER": true, "RIGHT": true}
+
+// hasSQL reports whether any of the (visible) keys holds a query in the data, so any SQLArgsKey is shown with it.
+func (f *Formatter) hasSQL(keys []string, data map[string]interface{}) bool {
+	for _, key := range keys {
+		if !f.isSQLKey(key) {
+			continue
+		}
+		if _, ok := f.resolved(data[key]).(string); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isSQLKey reports whether the key's values should be rendered as sql.
+func (f *Formatter) isSQLKey(key string) bool {
+	keys := f.SQLKeys
+	if keys == nil {
+		keys = DefaultSQLKeys
+	}
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlTokens splits the query into words, quoted strings and identifiers, comments and punctuation, dropping the
+// whitespace between them.
+func sqlTokens(query string) []string {
+	var tokens []string
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '\'' || r == '"' || r == '`':
+			i++
+			for i < len(runes) {
+				if runes[i] == r {
+					// Doubled quotes are escaped ones.
+					if i+1 < len(runes) && runes[i+1] == r {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case isSQLWord(r):
+			for i < len(runes) && isSQLWord(runes[i]) {
+				i++
+			}
+		default:
+			i++
+		}
+		tokens = append(tokens, string(runes[start:i]))
+	}
+	return tokens
+}
+
+// isSQLWord reports whether the rune can be part of a keyword, identifier, number or placeholder.
+func isSQLWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.$@:?", r)
+}
+
+// reflowSQL breaks the query into lines at each clause, with conditions and subqueries indented, highlighting the
+// keywords.
+func reflowSQL(query string, keyword func(string) string) []string {
+	tokens := sqlTokens(query)
+
+	var lines []string
+	var line bytes.Buffer
+	depth := 0
+	newLine := func(indent int) {
+		if strings.TrimSpace(line.String()) != "" {
+			lines = append(lines, line.String())
+		}
+		line.Reset()
+		writeSpaces(&line, indent)
+	}
+
+	for i, token := range tokens {
+		upper := strings.ToUpper(token)
+		next := ""
+		if i+1 < len(tokens) {
+			next = strings.ToUpper(tokens[i+1])
+		}
+		prev := ""
+		if i > 0 {
+			prev = strings.ToUpper(tokens[i-1])
+		}
+
+		switch {
+		case sqlClauses[upper] && !(upper == "JOIN" && sqlJoins[prev]) && !(upper == "UPDATE" && prev == "DO"):
+			newLine(depth * 2)
+		case sqlJoins[upper] && !sqlJoins[prev] && (next == "JOIN" || sqlJoins[next]):
+			newLine(depth * 2)
+		case upper == "AND" || upper == "OR":
+			newLine(depth*2 + 2)
+		case token == ")":
+			depth--
+			if depth < 0 {
+				depth = 0
+			}
+		}
+
+		// Function calls keep their parentheses, as do keywords like `IN (`.
+		call := token == "(" && prev != "" && isSQLWord([]rune(prev)[0]) && !sqlKeywords[prev]
+		trimmed := bytes.TrimRight(line.Bytes(), " ")
+		if len(trimmed) > 0 && !bytes.HasSuffix(trimmed, []byte("(")) && token != "," && token != ")" && !call {
+			line.WriteByte(' ')
+		}
+		if sqlKeywords[upper] {
+			line.WriteString(keyword(token))
+		} else {
+			line.WriteString(token)
+		}
+
+		if token == "(" {
+			depth++
+		} else if strings.HasPrefix(token, "--") {
+			newLine(depth * 2)
+		}
+	}
+	if strings.TrimSpace(line.String()) != "" {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// writeSQL writes the query's lines, the first on the current line and the rest at the column, followed by any bound
+// arguments.
+func writeSQL(b *bytes.Buffer, lines []string, args []byte, column int, dim func(string) string) {
+	for i, line := range lines {
+		if i > 0 {
+			b.Write(bNewline)
+			writeSpaces(b, column)
+		}
+		b.WriteString(line)
+	}
+	if args != nil {
+		b.Write(bNewline)
+		writeSpaces(b, column)
+		b.WriteString(dim("-- args: "))
+		writeCompact(b, args)
+	}
+}
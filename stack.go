@@ -0,0 +1,86 @@
+package formatrus
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// DefaultStackTopFrames is the number of frames highlighted at the top of a stack trace when StackTopFrames isn't set.
+const DefaultStackTopFrames = 3
+
+// stackFrame is a function and its location in a stack trace.
+type stackFrame struct {
+	function string
+	location string
+}
+
+// stackFrames returns the frames of a `[]runtime.Frame`, or of a string holding a Go stack trace (such as that from
+// `debug.Stack()`).
+func (f *Formatter) stackFrames(v interface{}) ([]stackFrame, bool) {
+	switch t := v.(type) {
+	case []runtime.Frame:
+		frames := make([]stackFrame, 0, len(t))
+		for _, frame := range t {
+			frames = append(frames, stackFrame{
+				function: frame.Function,
+				location: strings.TrimPrefix(frame.File, f.CallerTrimPrefix) + ":" + strconv.Itoa(frame.Line),
+			})
+		}
+		return frames, len(frames) > 0
+	case string:
+		return f.parseStack(t)
+	}
+	return nil, false
+}
+
+// parseStack parses the text of a Go stack trace, where each function is followed by a tab indented location.
+func (f *Formatter) parseStack(text string) ([]stackFrame, bool) {
+	if !strings.Contains(text, "\n\t") {
+		return nil, false
+	}
+
+	var frames []stackFrame
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" || strings.HasPrefix(line, "goroutine ") {
+			continue
+		}
+		if strings.HasPrefix(line, "\t") || i+1 == len(lines) || !strings.HasPrefix(lines[i+1], "\t") {
+			// Not a stack trace, or at least not one we understand.
+			return nil, false
+		}
+		location := strings.TrimSpace(lines[i+1])
+		if j := strings.LastIndex(location, " +0x"); j >= 0 {
+			location = location[:j]
+		}
+		frames = append(frames, stackFrame{
+			function: line,
+			location: strings.TrimPrefix(location, f.CallerTrimPrefix),
+		})
+		i++
+	}
+	return frames, len(frames) > 0
+}
+
+// writeStack writes a line per frame, the first on the current line and the rest at the column, dimming all but the
+// top frames.
+func (f *Formatter) writeStack(b *bytes.Buffer, frames []stackFrame, column int, top, dim func(string) string) {
+	highlighted := f.StackTopFrames
+	if highlighted == 0 {
+		highlighted = DefaultStackTopFrames
+	}
+	for i, frame := range frames {
+		if i > 0 {
+			b.Write(bNewline)
+			writeSpaces(b, column)
+		}
+		colour := dim
+		if i < highlighted {
+			colour = top
+		}
+		b.WriteString(colour(frame.function + "  " + frame.location))
+	}
+}
@@ -0,0 +1,171 @@
+package formatrus
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Style selects the overall output format.
+type Style int
+
+const (
+	// StyleDefault is the human readable output.
+	StyleDefault Style = iota
+	// StyleSyslog renders each entry as an RFC 5424 syslog message, with the data fields as structured data.
+	StyleSyslog
+)
+
+// DefaultSyslogSDID is the structured data id for the data fields, using the enterprise number reserved for
+// documentation.
+const DefaultSyslogSDID = "fields@32473"
+
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// syslogSeverity maps a logrus level to a syslog severity, as the logrus syslog hook does.
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 2
+	case logrus.ErrorLevel:
+		return 3
+	case logrus.WarnLevel:
+		return 4
+	case logrus.InfoLevel:
+		return 6
+	}
+	return 7
+}
+
+// sdName makes a data key safe for use as an SD-NAME, which is up to 32 printable characters other than space, `=`,
+// `]` and `"`.
+func sdName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name
+}
+
+// nilValue returns the value, or the RFC 5424 nil value ("-") if it's empty.
+func nilValue(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
+
+// formatSyslog renders the entry as an RFC 5424 syslog message.
+func (f *Formatter) formatSyslog(entry *logrus.Entry, b *bytes.Buffer) error {
+	when := entry.Time
+	if f.FixZeroTime && when.IsZero() {
+		when = time.Now()
+	}
+	if f.TimestampUTC {
+		when = when.UTC()
+	}
+
+	appName := f.SyslogAppName
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+	timestamp, host, pid := when.Format(syslogTimeFormat), hostname, strconv.Itoa(os.Getpid())
+	if f.Deterministic {
+		timestamp, host, pid = "", "", ""
+	}
+	fmt.Fprintf(b, "<%d>1 %s %s %s %s - ",
+		f.SyslogFacility*8+syslogSeverity(entry.Level),
+		nilValue(timestamp),
+		nilValue(host),
+		nilValue(appName),
+		nilValue(pid),
+	)
+
+	redactor := f.redactor()
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		if key == OrderKey {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		b.WriteByte('-')
+	} else {
+		sdID := f.SyslogSDID
+		if sdID == "" {
+			sdID = DefaultSyslogSDID
+		}
+		b.WriteByte('[')
+		b.WriteString(sdID)
+		for _, key := range keys {
+			value := entry.Data[key]
+			if redactor.matches(key) {
+				value = Redacted
+			}
+			value = f.resolved(value)
+			if p, ok := value.(panicked); ok {
+				value = panicText(p.r)
+			}
+
+			if v, ok := value.(error); ok {
+				value = f.safeText(v.Error)
+			}
+			text, ok := value.(string)
+			if !ok {
+				data, err := f.marshal(value)
+				if err != nil {
+					if f.Strict {
+						return fmt.Errorf("formatrus: unable to render field %q: %v", key, err)
+					}
+					data = []byte(f.safeText(func() string {
+						return stringify(value)
+					}))
+				}
+				text = string(data)
+			}
+			fmt.Fprintf(b, ` %s="%s"`, sdName(key), sdEscaper.Replace(text))
+		}
+		b.WriteByte(']')
+	}
+
+	if entry.Message != "" {
+		b.WriteByte(' ')
+		b.WriteString(lineBreaks.Replace(entry.Message))
+	}
+	b.WriteByte('\n')
+	return nil
+}
+
+// journal prefixes each line of the output with its syslog priority, if `JournaldPriority` is set.
+func (f *Formatter) journal(level logrus.Level, out []byte) []byte {
+	if !f.JournaldPriority || len(out) == 0 {
+		return out
+	}
+	prefix := []byte(fmt.Sprintf("<%d>", syslogSeverity(level)))
+	lines := bytes.SplitAfter(out, bNewline)
+	res := make([]byte, 0, len(out)+len(lines)*len(prefix))
+	for _, line := range lines {
+		if len(line) > 0 {
+			res = append(res, prefix...)
+			res = append(res, line...)
+		}
+	}
+	return res
+}
@@ -0,0 +1,69 @@
+package formatrus
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+type tee struct {
+	mu        sync.Mutex
+	w         io.Writer
+	formatter logrus.Formatter
+}
+
+// Tee also renders every entry with the given formatter and writes it to w, so that a single logger can produce
+// both pretty and machine readable output (chainable call). A nil formatter renders each entry as single line json.
+// Tee'd entries are written before the primary output, and aren't affected by deduplication.
+func (f *Formatter) Tee(w io.Writer, formatter logrus.Formatter) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tees := make([]*tee, len(f.tees), len(f.tees)+1)
+	copy(tees, f.tees)
+	f.tees = append(tees, &tee{w: w, formatter: formatter})
+	return f
+}
+
+// writeTees renders the entry to each of the tee'd writers.
+func (f *Formatter) writeTees(entry *logrus.Entry) error {
+	r := f.root()
+	r.mu.RLock()
+	tees := r.tees
+	r.mu.RUnlock()
+
+	for _, t := range tees {
+		if err := f.writeTee(t, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTee renders the entry with the tee's formatter and writes it out.
+func (f *Formatter) writeTee(t *tee, entry *logrus.Entry) error {
+	// The entry's buffer belongs to the primary output, so the tee renders into its own.
+	e := *entry
+	e.Buffer = nil
+
+	var data []byte
+	if t.formatter == nil {
+		b := &bytes.Buffer{}
+		if err := f.formatJSON(&e, b); err != nil {
+			return err
+		}
+		data = b.Bytes()
+	} else {
+		var err error
+		if data, err = t.formatter.Format(&e); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.w.Write(data)
+	return err
+}
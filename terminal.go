@@ -0,0 +1,146 @@
+package formatrus
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// TerminalChecker decides whether a writer is a terminal.
+type TerminalChecker interface {
+	IsTerminal(w io.Writer) bool
+}
+
+// TerminalCheckerFunc adapts a function to the TerminalChecker interface.
+type TerminalCheckerFunc func(w io.Writer) bool
+
+// IsTerminal calls fn(w).
+func (fn TerminalCheckerFunc) IsTerminal(w io.Writer) bool {
+	return fn(w)
+}
+
+// TerminalHinter is implemented by writers that know whether they (eventually) write to a terminal, such as writer
+// decorators that buffer or rotate output.
+type TerminalHinter interface {
+	IsTerminal() bool
+}
+
+// SetOutputIsTerminal overrides terminal detection for the given writer (chainable call).
+func (f *Formatter) SetOutputIsTerminal(w io.Writer, isTerminal bool) *Formatter {
+	f.termMu.Lock()
+	defer f.termMu.Unlock()
+
+	if f.terminals == nil {
+		f.terminals = map[io.Writer]bool{}
+	}
+	f.terminals[w] = isTerminal
+	return f
+}
+
+// outputIsTerminal reports whether the entry's logger writes to a terminal, caching the result per writer.
+func (f *Formatter) outputIsTerminal(entry *logrus.Entry) bool {
+	if entry.Logger == nil || entry.Logger.Out == nil {
+		return false
+	}
+	w := entry.Logger.Out
+	if terminal, ok := w.(sprintOutput); ok {
+		return bool(terminal)
+	}
+
+	// Writers that can't be map keys can't be cached, but they can't be files either.
+	if !reflect.TypeOf(w).Comparable() {
+		return f.detectTerminal(w)
+	}
+
+	r := f.root()
+	r.termMu.RLock()
+	isTerminal, ok := r.terminals[w]
+	r.termMu.RUnlock()
+	if ok {
+		return isTerminal
+	}
+
+	isTerminal = f.detectTerminal(w)
+
+	r.termMu.Lock()
+	if r.terminals == nil {
+		r.terminals = map[io.Writer]bool{}
+	}
+	r.terminals[w] = isTerminal
+	r.termMu.Unlock()
+
+	return isTerminal
+}
+
+// terminalWidth returns the width of the terminal the entry's logger writes to, or 0 if it isn't known (which it
+// never is in Deterministic mode).
+func (f *Formatter) terminalWidth(entry *logrus.Entry) int {
+	if entry.Logger == nil || f.Deterministic {
+		return 0
+	}
+	return writerWidth(entry.Logger.Out)
+}
+
+// writerWidth returns the width of the terminal behind the writer, unwrapping it as per detectTerminal, or 0 if there
+// isn't one. Multi writers have the width of their first terminal.
+func writerWidth(w io.Writer) int {
+	switch v := w.(type) {
+	case *os.File:
+		if width, _, err := term.GetSize(int(v.Fd())); err == nil {
+			return width
+		}
+	case interface{ Unwrap() io.Writer }:
+		return writerWidth(v.Unwrap())
+	case interface{ Writers() []io.Writer }:
+		for _, w := range v.Writers() {
+			if width := writerWidth(w); width > 0 {
+				return width
+			}
+		}
+	}
+	return 0
+}
+
+// detectTerminal reports whether the writer is a terminal that can display colour.
+// Writers that implement TerminalHinter are asked, wrappers with an `Unwrap() io.Writer` method are unwrapped, and
+// multi writers with a `Writers() []io.Writer` method are only terminals if all of their writers are. Other writers
+// that aren't files can't be inspected, and are treated as non-terminals. That includes an `io.MultiWriter`, which
+// has no `Writers()` method. Windows consoles that can't be switched to process ansi sequences are also treated as
+// non-terminals.
+func (f *Formatter) detectTerminal(w io.Writer) bool {
+	if f.TerminalChecker != nil {
+		return f.TerminalChecker.IsTerminal(w)
+	}
+
+	switch v := w.(type) {
+	case TerminalHinter:
+		return v.IsTerminal()
+	case interface{ Unwrap() io.Writer }:
+		return f.detectTerminal(v.Unwrap())
+	case interface{ Writers() []io.Writer }:
+		return f.allTerminals(v.Writers())
+	case *os.File:
+		if !term.IsTerminal(int(v.Fd())) {
+			return false
+		}
+		if runtime.GOOS == "windows" && f.DisableWindowsColor {
+			return false
+		}
+		return consoleColour(v)
+	}
+	return false
+}
+
+// allTerminals reports whether all of the writers are terminals.
+func (f *Formatter) allTerminals(writers []io.Writer) bool {
+	for _, w := range writers {
+		if !f.detectTerminal(w) {
+			return false
+		}
+	}
+	return len(writers) > 0
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package formatrus
+
+import (
+	"os"
+)
+
+// consoleColour reports whether ansi escape sequences will be understood by the terminal, which they always are
+// outside of Windows.
+func consoleColour(file *os.File) bool {
+	return true
+}
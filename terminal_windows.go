@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package formatrus
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// consoleColour enables virtual terminal processing on the console (Windows 10 onwards), reporting whether ansi
+// escape sequences will be understood.
+func consoleColour(file *os.File) bool {
+	h := windows.Handle(file.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -0,0 +1,279 @@
+package formatrus
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/sirupsen/logrus"
+)
+
+// Theme holds the ansi styles (such as "green", "red+b" or "black+h") used for each element of the output.
+// Colours may also be numbers from the 256 colour palette (e.g. "244") or 24-bit "#rrggbb" values, which are
+// downgraded to the closest colour the terminal can display (see `ColorDepth`).
+// Any elements left empty use the style from `DefaultTheme`.
+type Theme struct {
+	Trace string
+	Debug string
+	Info  string
+	Warn  string
+	Error string
+	Fatal string
+	Panic string
+
+	// Key is the style for data keys.
+	Key string
+	// Prefix is the style for the prefix/rpc.
+	Prefix string
+	// User is the style for the user.
+	User string
+	// Time is the style for the timestamp.
+	Time string
+	// Caller is the style for the caller's location.
+	Caller string
+	// Dim is the style for secondary text, such as sequence numbers and notes.
+	Dim string
+	// Emphasis is the style for the level of fatal and panic entries when `EmphasiseSevere` is set.
+	Emphasis string
+	// Banner is the style for the separators around fatal and panic entries when `SevereBanner` is set.
+	Banner string
+	// DiffAdd is the style for added values in `DiffPairs`.
+	DiffAdd string
+	// DiffRemove is the style for removed values in `DiffPairs`.
+	DiffRemove string
+	// DiffChange is the style for changed values in `DiffPairs`.
+	DiffChange string
+	// StackTop is the style for the top frames of stack traces (the rest use Dim).
+	StackTop string
+	// Keyword is the style for keywords in sql queries.
+	Keyword string
+
+	// JSONKey is the style for the keys of nested json values (the json styles are read when the formatter is first
+	// used).
+	JSONKey string
+	// JSONString is the style for json strings.
+	JSONString string
+	// JSONBool is the style for json booleans.
+	JSONBool string
+	// JSONNumber is the style for json numbers.
+	JSONNumber string
+	// JSONNull is the style for json nulls.
+	JSONNull string
+}
+
+// DefaultTheme is the theme used when no other styles are set.
+var DefaultTheme = Theme{
+	Trace:  "white",
+	Debug:  "blue",
+	Info:   "green",
+	Warn:   "yellow",
+	Error:  "red",
+	Fatal:  "red",
+	Panic:  "red",
+	Key:    "cyan",
+	Prefix: "magenta",
+	User:   "magenta+h",
+	Time:   "black+h",
+	Caller: "black+h",
+	Dim:    "black+h",
+
+	Emphasis: "white+b:red",
+	Banner:   "red+b",
+
+	DiffAdd:    "green",
+	DiffRemove: "red",
+	DiffChange: "yellow",
+
+	StackTop: "white",
+	Keyword:  "blue+b",
+
+	JSONKey:    "blue+b",
+	JSONString: "green+b",
+	JSONBool:   "yellow+b",
+	JSONNumber: "cyan+b",
+	JSONNull:   "black+b",
+}
+
+func pick(style, fallback string) string {
+	if style == "" {
+		return fallback
+	}
+	return style
+}
+
+// merged returns the theme with any empty elements filled from `DefaultTheme`.
+func (t Theme) merged() Theme {
+	d := DefaultTheme
+	return Theme{
+		Trace:  pick(t.Trace, d.Trace),
+		Debug:  pick(t.Debug, d.Debug),
+		Info:   pick(t.Info, d.Info),
+		Warn:   pick(t.Warn, d.Warn),
+		Error:  pick(t.Error, d.Error),
+		Fatal:  pick(t.Fatal, d.Fatal),
+		Panic:  pick(t.Panic, d.Panic),
+		Key:    pick(t.Key, d.Key),
+		Prefix: pick(t.Prefix, d.Prefix),
+		User:   pick(t.User, d.User),
+		Time:   pick(t.Time, d.Time),
+		Caller: pick(t.Caller, d.Caller),
+		Dim:    pick(t.Dim, d.Dim),
+
+		Emphasis: pick(t.Emphasis, d.Emphasis),
+		Banner:   pick(t.Banner, d.Banner),
+
+		DiffAdd:    pick(t.DiffAdd, d.DiffAdd),
+		DiffRemove: pick(t.DiffRemove, d.DiffRemove),
+		DiffChange: pick(t.DiffChange, d.DiffChange),
+
+		StackTop: pick(t.StackTop, d.StackTop),
+		Keyword:  pick(t.Keyword, d.Keyword),
+
+		JSONKey:    pick(t.JSONKey, d.JSONKey),
+		JSONString: pick(t.JSONString, d.JSONString),
+		JSONBool:   pick(t.JSONBool, d.JSONBool),
+		JSONNumber: pick(t.JSONNumber, d.JSONNumber),
+		JSONNull:   pick(t.JSONNull, d.JSONNull),
+	}
+}
+
+// level returns the style for the given level.
+func (t Theme) level(level logrus.Level) string {
+	switch level {
+	case logrus.InfoLevel:
+		return t.Info
+	case logrus.WarnLevel:
+		return t.Warn
+	case logrus.ErrorLevel:
+		return t.Error
+	case logrus.FatalLevel:
+		return t.Fatal
+	case logrus.PanicLevel:
+		return t.Panic
+	case logrus.TraceLevel:
+		return t.Trace
+	}
+	return t.Debug
+}
+
+// palette is the merged theme and the colour functions for its styles, which are cached on the formatter rather than
+// being built for every entry. It's rebuilt when the options it was built from change.
+type palette struct {
+	option  Theme
+	debug   bool
+	depth   ColorDepth
+	theme   Theme
+	colours map[string]func(string) string
+	build   func(style string) func(string) string
+}
+
+// palette returns the palette for the formatter's current options.
+func (f *Formatter) palette() *palette {
+	depth := f.colourDepth()
+	f.paletteMu.RLock()
+	p := f.colours
+	f.paletteMu.RUnlock()
+	if p != nil && p.option == f.Theme && p.debug == f.DebugColor && p.depth == depth {
+		return p
+	}
+
+	theme := f.Theme.merged()
+	p = &palette{
+		option:  f.Theme,
+		debug:   f.DebugColor,
+		depth:   depth,
+		theme:   theme,
+		colours: map[string]func(string) string{},
+		build:   f.colourFunc,
+	}
+	styles := reflect.ValueOf(theme)
+	for i := 0; i < styles.NumField(); i++ {
+		if style := styles.Field(i).String(); p.colours[style] == nil {
+			p.colours[style] = f.colourFunc(style)
+		}
+	}
+	f.paletteMu.Lock()
+	f.colours = p
+	f.paletteMu.Unlock()
+	return p
+}
+
+// colour returns the function that applies the style, which is only built afresh if it isn't one of the theme's.
+func (p *palette) colour(style string) func(string) string {
+	if c := p.colours[style]; c != nil {
+		return c
+	}
+	return p.build(style)
+}
+
+var colourNames = map[string]color.Attribute{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+}
+
+// jsonColour converts an ansi style (as used by the theme) into a colour for the json pretty printer, at the given
+// colour depth.
+func jsonColour(style string, depth ColorDepth) *color.Color {
+	style, fgRGB, bgRGB := depthStyle(style, depth)
+	fg, bg := style, ""
+	if i := strings.IndexByte(style, ':'); i >= 0 {
+		fg, bg = style[:i], style[i+1:]
+	}
+
+	c := color.New()
+	name, attrs := splitStyle(fg)
+	addColour(c, name, strings.Contains(attrs, "h"), 30)
+	addRGB(c, fgRGB, 30)
+	for _, a := range attrs {
+		switch a {
+		case 'b':
+			c.Add(color.Bold)
+		case 'B':
+			c.Add(color.BlinkSlow)
+		case 'u':
+			c.Add(color.Underline)
+		case 'i':
+			c.Add(color.ReverseVideo)
+		case 's':
+			c.Add(color.CrossedOut)
+		}
+	}
+	name, attrs = splitStyle(bg)
+	addColour(c, name, strings.Contains(attrs, "h"), 40)
+	addRGB(c, bgRGB, 40)
+	return c
+}
+
+// splitStyle separates a colour name from its attributes.
+func splitStyle(style string) (name, attrs string) {
+	if i := strings.IndexByte(style, '+'); i >= 0 {
+		return style[:i], style[i+1:]
+	}
+	return style, ""
+}
+
+// addColour adds a named (or 256 colour numbered) foreground or background colour.
+func addColour(c *color.Color, name string, high bool, base color.Attribute) {
+	if n, ok := colourNames[name]; ok {
+		if high {
+			n += 60
+		}
+		c.Add(base + n)
+	} else if n, err := strconv.Atoi(name); err == nil && n >= 0 && n < 256 {
+		c.Add(base+8, 5, color.Attribute(n))
+	}
+}
+
+// addRGB adds a 24-bit foreground or background colour, if there is one.
+func addRGB(c *color.Color, rgb *[3]int, base color.Attribute) {
+	if rgb != nil {
+		c.Add(base+8, 2, color.Attribute(rgb[0]), color.Attribute(rgb[1]), color.Attribute(rgb[2]))
+	}
+}
@@ -0,0 +1,115 @@
+package formatrus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimestampFormat is the layout used for timestamps when no TimestampFormat is set.
+const DefaultTimestampFormat = "Jan 02 15:04:05.000"
+
+// Special TimestampFormat values that render the time as a number since the Unix epoch.
+const (
+	// TimestampUnix renders the time as seconds since the epoch.
+	TimestampUnix = "unix"
+	// TimestampUnixMilli renders the time as milliseconds since the epoch.
+	TimestampUnixMilli = "unixmilli"
+)
+
+// TimePrecision denotes the fractional seconds shown in timestamps.
+type TimePrecision int
+
+const (
+	// PrecisionDefault uses whatever fractional seconds the TimestampFormat contains.
+	PrecisionDefault TimePrecision = iota
+	// PrecisionSeconds shows whole seconds.
+	PrecisionSeconds
+	// PrecisionMillis shows milliseconds.
+	PrecisionMillis
+	// PrecisionMicros shows microseconds.
+	PrecisionMicros
+	// PrecisionNanos shows nanoseconds.
+	PrecisionNanos
+)
+
+var reFraction = regexp.MustCompile(`05[.,](0+|9+)`)
+
+var fractions = map[TimePrecision]string{
+	PrecisionSeconds: "",
+	PrecisionMillis:  ".000",
+	PrecisionMicros:  ".000000",
+	PrecisionNanos:   ".000000000",
+}
+
+// withPrecision replaces any fractional seconds in the layout with those of the given precision.
+func withPrecision(layout string, precision TimePrecision) string {
+	fraction, ok := fractions[precision]
+	if !ok {
+		return layout
+	}
+	layout = reFraction.ReplaceAllString(layout, "05")
+	return strings.Replace(layout, "05", "05"+fraction, 1)
+}
+
+// TimeMode denotes what the timestamp shows.
+type TimeMode int
+
+const (
+	// TimeAbsolute shows the time of the entry.
+	TimeAbsolute TimeMode = iota
+	// TimeElapsed shows the time since the process started (e.g. `+0012.345s`).
+	TimeElapsed
+	// TimeDelta shows the time since the previous entry formatted by this formatter.
+	TimeDelta
+)
+
+// processStart approximates when the process started, for TimeElapsed.
+var processStart = time.Now()
+
+// relative renders a duration in the elapsed/delta form, signed so that out of order entries show a negative delta.
+func relative(d time.Duration) string {
+	return fmt.Sprintf("%+09.3fs", d.Seconds())
+}
+
+// moment renders the time for the header, according to the TimeMode, recording it for the next TimeDelta if record
+// is set.
+func (f *Formatter) moment(t time.Time, record bool) string {
+	switch f.TimeMode {
+	case TimeElapsed:
+		return relative(t.Sub(processStart))
+	case TimeDelta:
+		r := f.root()
+		r.timeMu.Lock()
+		last := r.lastTime
+		if record {
+			r.lastTime = t
+		}
+		r.timeMu.Unlock()
+		if last.IsZero() {
+			return relative(0)
+		}
+		return relative(t.Sub(last))
+	}
+	return f.timestamp(t)
+}
+
+// timestamp renders the time according to the configured TimestampFormat and TimestampUTC.
+func (f *Formatter) timestamp(t time.Time) string {
+	if f.TimestampUTC {
+		t = t.UTC()
+	}
+
+	layout := f.TimestampFormat
+	switch layout {
+	case "":
+		layout = DefaultTimestampFormat
+	case TimestampUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimestampUnixMilli:
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return t.Format(withPrecision(layout, f.TimePrecision))
+}
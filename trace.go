@@ -0,0 +1,47 @@
+package formatrus
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TraceExtractor returns the trace and span ids carried by a context, or empty strings if there are none.
+// See the `otelformatrus` package for an OpenTelemetry extractor.
+type TraceExtractor func(ctx context.Context) (traceID, spanID string)
+
+// traceIDs returns the trace and span ids for the entry's context.
+func (f *Formatter) traceIDs(entry *logrus.Entry) (traceID, spanID string) {
+	if f.TraceExtractor == nil || entry.Context == nil {
+		return "", ""
+	}
+	return f.TraceExtractor(entry.Context)
+}
+
+// traceKeys returns the keys the trace and span ids are rendered under.
+func (f *Formatter) traceKeys() (traceKey, spanKey string) {
+	traceKey, spanKey = f.TraceIDKey, f.SpanIDKey
+	if traceKey == "" {
+		traceKey = "trace_id"
+	}
+	if spanKey == "" {
+		spanKey = "span_id"
+	}
+	return traceKey, spanKey
+}
+
+// traceText renders the ids as a compact `key=value` suffix.
+func (f *Formatter) traceText(traceID, spanID string) string {
+	traceKey, spanKey := f.traceKeys()
+	text := ""
+	if traceID != "" {
+		text = traceKey + "=" + traceID
+	}
+	if spanID != "" {
+		if text != "" {
+			text += " "
+		}
+		text += spanKey + "=" + spanID
+	}
+	return text
+}
@@ -0,0 +1,86 @@
+package formatrus
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+var hostname, _ = os.Hostname()
+
+// trailer returns the process metadata to show at the end of the header line.
+func (f *Formatter) trailer(entry *logrus.Entry) string {
+	var parts []string
+	filter := f.fieldFilter()
+	show := func(key string) bool {
+		return f.keyVisible(filter, key, entry.Level)
+	}
+	// The process metadata changes between runs, so isn't deterministic.
+	if !f.Deterministic {
+		if f.ShowPID && show("pid") {
+			parts = append(parts, "pid="+strconv.Itoa(os.Getpid()))
+		}
+		if f.ShowHostname && hostname != "" && show("host") {
+			parts = append(parts, "host="+hostname)
+		}
+		if f.ShowGoroutine && show("goroutine") {
+			parts = append(parts, "goroutine="+goroutineID())
+		}
+	}
+	if f.TrailerFunc != nil {
+		if text := f.TrailerFunc(entry); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// goroutineID returns the id of the current goroutine, which is the one doing the logging.
+func goroutineID() string {
+	var buf [64]byte
+	stack := buf[:runtime.Stack(buf[:], false)]
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i > 0 {
+		return string(stack[:i])
+	}
+	return "?"
+}
+
+// writeTrailer writes the trailer at the end of the current line, right aligned to the width if there's room.
+func writeTrailer(b *bytes.Buffer, trailer string, width int) {
+	line := b.Bytes()
+	if i := bytes.LastIndexByte(line, '\n'); i >= 0 {
+		line = line[i+1:]
+	}
+	pad := width - visibleWidth(line) - visibleWidth([]byte(trailer))
+	if pad < 1 {
+		pad = 1
+	}
+	writeSpaces(b, pad)
+	b.WriteString(trailer)
+}
+
+// writeSeparator writes the Separator (repeated across the line if it's narrower) before every SeparatorEvery entries,
+// except the first.
+func (f *Formatter) writeSeparator(b *bytes.Buffer, entry *logrus.Entry, dim func(string) string) {
+	n := atomic.AddUint64(&f.root().separated, 1) - 1
+	every := uint64(1)
+	if f.SeparatorEvery > 1 {
+		every = uint64(f.SeparatorEvery)
+	}
+	if n == 0 || n%every != 0 {
+		return
+	}
+
+	rule := f.Separator
+	if w := visibleWidth([]byte(rule)); w > 0 && w < f.lineWidth(entry) {
+		rule = strings.Repeat(rule, f.lineWidth(entry)/w)
+	}
+	b.WriteString(dim(rule))
+	b.Write(bNewline)
+}
@@ -0,0 +1,177 @@
+package formatrus
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BytesMode denotes how `[]byte` values are rendered.
+type BytesMode int
+
+const (
+	// BytesDefault renders byte slices like any other slice (as a list of numbers).
+	BytesDefault BytesMode = iota
+	// BytesHex renders byte slices as hexadecimal.
+	BytesHex
+	// BytesBase64 renders byte slices as standard base64.
+	BytesBase64
+	// BytesString renders byte slices as text if they're printable utf-8, or hexadecimal otherwise.
+	BytesString
+	// BytesLength renders only the length of byte slices.
+	BytesLength
+)
+
+// bytesText renders the byte slice according to the mode.
+func bytesText(b []byte, mode BytesMode) interface{} {
+	switch mode {
+	case BytesHex:
+		return hex.EncodeToString(b)
+	case BytesBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case BytesString:
+		if printable(b) {
+			return string(b)
+		}
+		return hex.EncodeToString(b)
+	case BytesLength:
+		return fmt.Sprintf("(%d bytes)", len(b))
+	}
+	return b
+}
+
+// printable reports whether the byte slice is valid utf-8 made up of printable characters and whitespace.
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Renderer returns the json representation of a value.
+type Renderer func(v interface{}) ([]byte, error)
+
+// RegisterRenderer sets the renderer used for values of the given type, in place of the built-in conversions
+// (chainable call). The renderer must return valid json, e.g. `json.Marshal(v.(time.Duration).String())`.
+// If the type is an interface (e.g. `reflect.TypeOf((*fmt.Stringer)(nil)).Elem()`), the renderer is used for values
+// implementing it that don't have a renderer for their own type, with the first registered interface winning.
+func (f *Formatter) RegisterRenderer(t reflect.Type, renderer Renderer) *Formatter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	renderers := make(map[reflect.Type]Renderer, len(f.renderers)+1)
+	for k, r := range f.renderers {
+		renderers[k] = r
+	}
+	if _, ok := renderers[t]; !ok && t.Kind() == reflect.Interface {
+		f.interfaces = append(f.interfaces[:len(f.interfaces):len(f.interfaces)], t)
+	}
+	renderers[t] = renderer
+	f.renderers = renderers
+
+	return f
+}
+
+// renderer returns the registered renderer for the value's type, if any.
+func (f *Formatter) renderer(v interface{}) Renderer {
+	r := f.root()
+	r.mu.RLock()
+	renderers := r.renderers
+	interfaces := r.interfaces
+	r.mu.RUnlock()
+
+	if len(renderers) == 0 || v == nil {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	if renderer, ok := renderers[t]; ok {
+		return renderer
+	}
+	for _, i := range interfaces {
+		if t.Implements(i) {
+			return renderers[i]
+		}
+	}
+	return nil
+}
+
+// simplify converts values that have an awkward JSON representation into something more readable.
+func (f *Formatter) simplify(v interface{}) interface{} {
+	if f.NiceNetTypes {
+		switch t := v.(type) {
+		case net.IP:
+			return t.String()
+		case net.IPNet:
+			return t.String()
+		case *net.IPNet:
+			if t != nil {
+				return t.String()
+			}
+		case url.URL:
+			return t.String()
+		case *url.URL:
+			if t != nil {
+				return t.String()
+			}
+		}
+	}
+	if b, ok := v.([]byte); ok && f.BytesMode != BytesDefault {
+		return bytesText(b, f.BytesMode)
+	}
+	if f.NiceTimeTypes {
+		switch t := v.(type) {
+		case time.Duration:
+			return t.String()
+		case time.Time:
+			return t.Format(f.timeValueFormat())
+		case *time.Time:
+			if t != nil {
+				return t.Format(f.timeValueFormat())
+			}
+		}
+	}
+	return v
+}
+
+// timeValueFormat returns the layout for `time.Time` values.
+func (f *Formatter) timeValueFormat() string {
+	if f.TimeValueFormat == "" {
+		return time.RFC3339
+	}
+	return f.TimeValueFormat
+}
+
+// nonFinite returns the display form of NaN and infinite float values, which can't be marshalled as json.
+func nonFinite(v interface{}) (string, bool) {
+	var n float64
+	switch t := v.(type) {
+	case float64:
+		n = t
+	case float32:
+		n = float64(t)
+	default:
+		return "", false
+	}
+
+	switch {
+	case math.IsNaN(n):
+		return "NaN", true
+	case math.IsInf(n, 1):
+		return "+Inf", true
+	case math.IsInf(n, -1):
+		return "-Inf", true
+	}
+	return "", false
+}
@@ -0,0 +1,70 @@
+package formatrus
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WrapTerminal is a `WrapWidth` that wraps to the width of the terminal being written to.
+const WrapTerminal = -1
+
+// wrapWidth returns the width to wrap the entry's output at, or 0 to not wrap.
+func (f *Formatter) wrapWidth(entry *logrus.Entry) int {
+	if f.WrapWidth == WrapTerminal {
+		return f.terminalWidth(entry)
+	}
+	if f.WrapWidth > 0 {
+		return f.WrapWidth
+	}
+	return 0
+}
+
+// lineWidth returns the width of a full line of output, which is 80 columns if it isn't otherwise known.
+func (f *Formatter) lineWidth(entry *logrus.Entry) int {
+	if width := f.wrapWidth(entry); width > 0 {
+		return width
+	}
+	if width := f.terminalWidth(entry); width > 0 {
+		return width
+	}
+	return 80
+}
+
+// wrap soft wraps each line of the text at spaces so that it fits within width columns, where possible.
+// Words longer than the width are left whole rather than being broken.
+func wrap(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = wrapLine(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapLine soft wraps a single line of text.
+func wrapLine(line string, width int) string {
+	if visibleWidth([]byte(line)) <= width {
+		return line
+	}
+
+	var b strings.Builder
+	col := 0
+	for i, word := range strings.Split(line, " ") {
+		n := visibleWidth([]byte(word))
+		if i > 0 {
+			if col > 0 && col+1+n > width {
+				b.WriteByte('\n')
+				col = 0
+			} else {
+				b.WriteByte(' ')
+				col++
+			}
+		}
+		b.WriteString(word)
+		col += n
+	}
+	return b.String()
+}